@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/pegass"
+	"gopkg.in/urfave/cli.v1"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Output formats of commands printing rows.
+const (
+	OUTPUT_TABLE = "table"
+	OUTPUT_CSV   = "csv"
+	OUTPUT_JSON  = "json"
+)
+
+var OUTPUT_FORMATS = []string{OUTPUT_TABLE, OUTPUT_CSV, OUTPUT_JSON}
+
+var activitiesCommand = cli.Command{
+	Name:  "activities",
+	Usage: "List the seances of the zone between two days, with their status and registrations",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "from",
+			Usage: "first day, e.g. 2024-06-01 (default: today)",
+		},
+		cli.StringFlag{
+			Name:  "to",
+			Usage: "last day (default: the first day)",
+		},
+		cli.IntFlag{
+			Name:  "action",
+			Usage: "only keep activities of this action, e.g. 65 for the first aid network",
+		},
+		cli.IntFlag{
+			Name:  "type-activite",
+			Usage: "only keep activities of this type, e.g. 10115 for SAMU network activities",
+		},
+		cli.IntSliceFlag{
+			Name:  "structure",
+			Usage: "only keep activities carried out by this structure (may be repeated)",
+		},
+		cli.StringFlag{
+			Name:  "statut",
+			Usage: "only keep activities with this status, e.g. 'incomplete'",
+		},
+		cli.StringFlag{
+			Name:  "label",
+			Usage: "only keep activities whose name contains this text",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: fmt.Sprintf("output format (one of: %s)", strings.Join(OUTPUT_FORMATS, ", ")),
+			Value: OUTPUT_TABLE,
+		},
+	},
+	Action: func(c *cli.Context) error {
+		filter := pegass.SeanceFilter{
+			Action:       c.Int("action"),
+			TypeActivite: c.Int("type-activite"),
+			StructureIDs: c.IntSlice("structure"),
+			Statut:       c.String("statut"),
+			Label:        c.String("label"),
+		}
+		var err error
+		filter.From, err = parseDay(c.String("from"), time.Now())
+		if err != nil {
+			return err
+		}
+		filter.To, err = parseDay(c.String("to"), filter.From)
+		if err != nil {
+			return err
+		}
+		if filter.To.Before(filter.From) {
+			return fmt.Errorf("--to (%s) is before --from (%s)", filter.To.Format(time.DateOnly), filter.From.Format(time.DateOnly))
+		}
+
+		var format = c.String("format")
+		if format != OUTPUT_TABLE && format != OUTPUT_CSV && format != OUTPUT_JSON {
+			return fmt.Errorf("unknown output format '%s' (expected one of: %s)", format, strings.Join(OUTPUT_FORMATS, ", "))
+		}
+
+		ctx, cancel := commandContext()
+		defer cancel()
+		_, err = initClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		rows, err := pegassClient.FindSeancesContext(ctx, filter)
+		if err != nil {
+			return err
+		}
+		return writeSeanceRows(os.Stdout, rows, format)
+	},
+}
+
+// parseDay reads a YYYY-MM-DD day, falling back to defaultDay when value is empty.
+func parseDay(value string, defaultDay time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultDay, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return day, fmt.Errorf("invalid day '%s', expected YYYY-MM-DD: %w", value, err)
+	}
+	return day, nil
+}
+
+func writeSeanceRows(w io.Writer, rows []pegass.SeanceRow, format string) error {
+	switch format {
+	case OUTPUT_JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if rows == nil {
+			rows = []pegass.SeanceRow{}
+		}
+		return encoder.Encode(rows)
+	case OUTPUT_CSV:
+		writer := csv.NewWriter(w)
+		err := writer.Write([]string{"id", "activity", "structure", "start", "end", "status", "registered", "required"})
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			err = writer.Write([]string{
+				row.ID,
+				row.Activity,
+				row.Structure,
+				row.Start.Format(time.RFC3339),
+				row.End.Format(time.RFC3339),
+				row.Status,
+				strconv.Itoa(row.Registered),
+				strconv.Itoa(row.Required),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tACTIVITY\tSTRUCTURE\tSTART\tEND\tSTATUS\tREGISTERED")
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d/%d\n", row.ID, row.Activity, row.Structure,
+			row.Start.Format("2006-01-02 15:04"), row.End.Format("2006-01-02 15:04"), row.Status, row.Registered, row.Required)
+	}
+	return tw.Flush()
+}
@@ -2,49 +2,131 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/fabien-chebel/pegass-cli/logging"
+	"github.com/fabien-chebel/pegass-cli/metrics"
+	"github.com/fabien-chebel/pegass-cli/pegass"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"github.com/fabien-chebel/pegass-cli/summary"
 	"github.com/fabien-chebel/pegass-cli/whatsapp"
 	log "github.com/sirupsen/logrus"
 	"go.mau.fi/whatsmeow/types"
+	"net/http"
+	"sync"
 	"time"
 )
 
+const ADMIN_ALERT_INTERVAL = 6 * time.Hour
+
 type BotService struct {
-	pegassClient *PegassClient
+	pegassClient pegass.Client
 	chatClient   *whatsapp.WhatsAppClient
+	adminGroup   string
+	lastAlerts   map[string]time.Time
+	// pegassMutex serializes bot commands and metrics refreshes, which may both renew the Pegass session.
+	pegassMutex sync.Mutex
+	// metrics are only reported when served, i.e. when not nil.
+	metrics *metrics.Metrics
+}
+
+// countCommand reports the outcome of a bot command.
+func (b *BotService) countCommand(command string, err error) {
+	if b.metrics == nil {
+		return
+	}
+	outcome := metrics.OUTCOME_SUCCESS
+	if err != nil {
+		outcome = metrics.OUTCOME_FAILURE
+	}
+	b.metrics.BotCommands.WithLabelValues(command, outcome).Inc()
 }
 
-func (b *BotService) SendActivitySummary(recipient types.JID, kind ActivityKind, dayCount int) {
-	var kindName string
-	if kind == SAMU {
-		kindName = "SAMU"
-	} else {
-		kindName = "BSPP"
+// AlertAdmins sends a message to the admin group, if any. The same message is sent at most once every
+// ADMIN_ALERT_INTERVAL, so that a broken account does not flood the group.
+func (b *BotService) AlertAdmins(message string) {
+	if b.adminGroup == "" {
+		log.Warnf("no WhatsApp admin group configured, unable to send alert: %s", message)
+		return
+	}
+	if b.lastAlerts == nil {
+		b.lastAlerts = make(map[string]time.Time)
+	}
+	if lastAlert, ok := b.lastAlerts[message]; ok && time.Since(lastAlert) < ADMIN_ALERT_INTERVAL {
+		return
 	}
 
-	err := b.chatClient.SendMessage(fmt.Sprintf("🤖 C'est reçu. Je génère l'état des postes %s sur %d jours.", kindName, dayCount), recipient)
+	jid, err := types.ParseJID(b.adminGroup)
 	if err != nil {
-		log.Errorf("failed to send whatsapp message: %s", err.Error())
+		log.Errorf("invalid WhatsApp admin group '%s': %s", b.adminGroup, err.Error())
 		return
 	}
+	err = b.chatClient.SendMessage("🚨 "+message, jid)
+	if err != nil {
+		log.Errorf("failed to send alert to admins: %s", err.Error())
+		return
+	}
+	b.lastAlerts[message] = time.Now()
+}
+
+// HandleAuthenticationFailure tells the requester that Pegass cannot be reached, and alerts admins when
+// the service account needs a human to fix it.
+func (b *BotService) HandleAuthenticationFailure(ctx context.Context, recipient types.JID, authErr error) {
+	logging.FromContext(ctx).Errorf("failed to authenticate to pegass: '%s'", authErr.Error())
+
+	message := "Je n'arrive pas à me connecter à Pegass. Veuillez réessayer plus tard"
+	if redcross.IsAccountUnusable(authErr) {
+		b.AlertAdmins(fmt.Sprintf("Le compte de service ne peut plus se connecter à Pegass : %s", authErr.Error()))
+		message = "Le compte de service ne peut plus se connecter à Pegass. Les administrateurs ont été prévenus"
+	}
+
+	err := b.chatClient.SendMessageContext(ctx, "🤖 "+message, recipient)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("failed to notify user that Pegass authentication failed. Error:'%s'", err.Error())
+	}
+}
+
+// HandleAuthenticationWarning forwards non-blocking login issues, such as an upcoming password expiry, to admins.
+func (b *BotService) HandleAuthenticationWarning(warning error) {
+	var passwordWarning *redcross.PasswordWarning
+	if errors.As(warning, &passwordWarning) {
+		b.AlertAdmins(fmt.Sprintf("Le mot de passe du compte de service expire dans %d jour(s)", passwordWarning.DaysLeft))
+		return
+	}
+	b.AlertAdmins(fmt.Sprintf("Avertissement lors de la connexion à Pegass : %s", warning.Error()))
+}
+
+// SendActivitySummary replies with the activity summary of the next days. It gives up once ctx is done.
+func (b *BotService) SendActivitySummary(ctx context.Context, recipient types.JID, kind pegass.ActivityKind, dayCount int) error {
+	err := b.chatClient.SendMessageContext(ctx, fmt.Sprintf("🤖 C'est reçu. Je génère l'état des postes %s sur %d jours.", kind, dayCount), recipient)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("failed to send whatsapp message: %s", err.Error())
+		return err
+	}
 
 	for i := 0; i < dayCount; i++ {
 		var buf = new(bytes.Buffer)
 
 		day := time.Now().AddDate(0, 0, i).Format("2006-01-02")
-		log.Infof("fetching activity summary for day '%s' and kind '%#v'", day, kind)
-		summary, err := b.pegassClient.FindActivitiesOnDay(day, kind, false)
+		logging.FromContext(ctx).Infof("fetching activity summary for day '%s' and kind '%s'", day, kind)
+		daySummary, err := b.pegassClient.SummarizeDayContext(ctx, day, kind, false)
+		var message string
+		if err == nil {
+			message, err = summary.RenderWhatsApp(daySummary)
+		}
 		if err != nil {
-			log.Errorf("failed to generate activity summary for day '%s' and kind '%d'. error='%s'", day, kind, err.Error())
-			err := b.chatClient.SendMessage("Une erreur s'est produite lors de la génération de l'état du réseau. Veuillez réessayer plus tard", recipient)
-			if err != nil {
-				log.Errorf("failed to notify user that their request could not be processed. Error:'%s'", err.Error())
+			logging.FromContext(ctx).Errorf("failed to generate activity summary for day '%s' and kind '%s'. error='%s'", day, kind, err.Error())
+			// Still notify the user when the command ran out of time
+			notifyErr := b.chatClient.SendMessage(summaryErrorMessage(err), recipient)
+			if notifyErr != nil {
+				logging.FromContext(ctx).Errorf("failed to notify user that their request could not be processed. Error:'%s'", notifyErr.Error())
 			}
-			return
+			return err
 		}
 
-		if summary == "" {
-			summary = "Aucune activité trouvée"
+		if len(daySummary.Sections) == 0 {
+			message = "Aucune activité trouvée"
 		}
 
 		switch i {
@@ -56,11 +138,59 @@ func (b *BotService) SendActivitySummary(recipient types.JID, kind ActivityKind,
 			buf.WriteString(fmt.Sprintf("*Après-demain* (%s) :\n", day))
 		}
 
-		buf.WriteString(summary + "\n")
-		err = b.chatClient.SendMessage(
+		buf.WriteString(message + "\n")
+		err = b.chatClient.SendMessageContext(
+			ctx,
 			buf.String(),
 			recipient,
 		)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("failed to send whatsapp message: %s", err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshIncompleteSeances updates the gauge of incomplete seances in the next 24 hours, until ctx is done.
+func (b *BotService) RefreshIncompleteSeances(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		refreshCtx := logging.WithCorrelationID(ctx, logging.NewCorrelationID())
+		b.pegassMutex.Lock()
+		err := b.pegassClient.AuthenticateIfNecessaryContext(refreshCtx)
+		var counts map[string]int
+		if err == nil {
+			counts, err = b.pegassClient.CountIncompleteSeancesContext(refreshCtx, 24*time.Hour)
+		}
+		b.pegassMutex.Unlock()
+		if err != nil {
+			logging.FromContext(refreshCtx).Warnf("failed to count incomplete seances: %s", err)
+		} else {
+			for kind, count := range counts {
+				b.metrics.IncompleteSeances.WithLabelValues(kind).Set(float64(count))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
+// summaryErrorMessage explains to bot users why their request failed, when they can do something about it.
+func summaryErrorMessage(err error) string {
+	var apiError *redcross.PegassAPIError
+	switch {
+	case errors.Is(err, redcross.ErrPegassAuthentication):
+		return "🤖 Pegass a refusé ma session. Je me reconnecterai à la prochaine demande, veuillez réessayer dans quelques instants"
+	case errors.As(err, &apiError) && apiError.StatusCode >= http.StatusInternalServerError:
+		return fmt.Sprintf("🤖 Pegass semble indisponible pour le moment (erreur %d). Veuillez réessayer plus tard", apiError.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "🤖 Pegass met trop de temps à répondre. Veuillez réessayer plus tard"
+	}
+	return "Une erreur s'est produite lors de la génération de l'état du réseau. Veuillez réessayer plus tard"
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/cache"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/urfave/cli.v1"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func openCache(config Cache) (*cache.Cache, error) {
+	ttls := make(map[string]time.Duration)
+	for kind, minutes := range config.TTLMinutes {
+		if !slices.Contains(cache.Kinds, kind) {
+			return nil, fmt.Errorf("unknown cache kind '%s' (expected one of: %s)", kind, strings.Join(cache.Kinds, ", "))
+		}
+		ttls[kind] = time.Duration(minutes) * time.Minute
+	}
+	return cache.Open(profilePath("cache.db"), ttls)
+}
+
+var cacheCommand = cli.Command{
+	Name:  "cache",
+	Usage: "Inspect or clear the on-disk cache of Pegass responses",
+	Subcommands: []cli.Command{
+		{
+			Name:  "stats",
+			Usage: "Show the number of cached entries by kind",
+			Action: func(c *cli.Context) error {
+				store, err := openCache(parseConfig().Cache)
+				if err != nil {
+					return err
+				}
+				defer store.Close()
+
+				stats, err := store.Stats(context.Background())
+				if err != nil {
+					return err
+				}
+
+				fmt.Printf("Cache: %s\n", store.Path())
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "KIND\tTTL\tENTRIES\tEXPIRED\tSIZE\tOLDEST")
+				for _, s := range stats {
+					oldest := "-"
+					if !s.Oldest.IsZero() {
+						oldest = s.Oldest.Format(time.DateTime)
+					}
+					fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d KiB\t%s\n", s.Kind, s.TTL, s.Entries, s.Expired, s.Bytes/1024, oldest)
+				}
+				return w.Flush()
+			},
+		},
+		{
+			Name:      "clear",
+			Usage:     "Remove cached entries, of every kind or of the given one",
+			ArgsUsage: fmt.Sprintf("[%s]", strings.Join(cache.Kinds, "|")),
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "expired",
+					Usage: "only remove entries past their TTL",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				kind := c.Args().Get(0)
+				if kind != "" && !slices.Contains(cache.Kinds, kind) {
+					return fmt.Errorf("unknown cache kind '%s' (expected one of: %s)", kind, strings.Join(cache.Kinds, ", "))
+				}
+
+				store, err := openCache(parseConfig().Cache)
+				if err != nil {
+					return err
+				}
+				defer store.Close()
+
+				removed, err := store.Clear(context.Background(), kind, c.Bool("expired"))
+				if err != nil {
+					return err
+				}
+				log.Infof("Removed %d cache entries", removed)
+				return nil
+			},
+		},
+	},
+}
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	_ "github.com/glebarez/go-sqlite"
+	"time"
+)
+
+// Kinds of cached entities. Each one expires after its own TTL.
+const (
+	KindUsers      = "users"
+	KindPhones     = "phones"
+	KindTrainings  = "trainings"
+	KindStructures = "structures"
+	KindRoles      = "roles"
+	KindActivities = "activities"
+)
+
+// Kinds lists every kind of cached entity.
+var Kinds = []string{KindUsers, KindPhones, KindTrainings, KindStructures, KindRoles, KindActivities}
+
+// DefaultTTLs is how long entries stay fresh, by kind. Activities change often (registrations, status), while
+// structures and roles hardly ever do.
+var DefaultTTLs = map[string]time.Duration{
+	KindUsers:      24 * time.Hour,
+	KindPhones:     24 * time.Hour,
+	KindTrainings:  24 * time.Hour,
+	KindStructures: 7 * 24 * time.Hour,
+	KindRoles:      7 * 24 * time.Hour,
+	KindActivities: 5 * time.Minute,
+}
+
+const schema = `CREATE TABLE IF NOT EXISTS entries (
+	kind      TEXT    NOT NULL,
+	key       TEXT    NOT NULL,
+	value     BLOB    NOT NULL,
+	stored_at INTEGER NOT NULL,
+	PRIMARY KEY (kind, key)
+)`
+
+// Cache keeps JSON-encoded Pegass responses in a SQLite database, each kind of entity expiring after its TTL.
+type Cache struct {
+	db   *sql.DB
+	path string
+	ttls map[string]time.Duration
+}
+
+// KindStats describes the entries of a kind of entity.
+type KindStats struct {
+	Kind    string
+	TTL     time.Duration
+	Entries int
+	Expired int
+	Bytes   int64
+	Oldest  time.Time
+}
+
+// Open opens the cache stored at path, creating it if necessary. ttls overrides DefaultTTLs for some kinds.
+func Open(path string, ttls map[string]time.Duration) (*Cache, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)", path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache database: %w", err)
+	}
+	// Serialize writes, SQLite does not allow concurrent ones
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize cache database: %w", err)
+	}
+
+	c := &Cache{
+		db:   db,
+		path: path,
+		ttls: make(map[string]time.Duration),
+	}
+	for kind, ttl := range DefaultTTLs {
+		c.ttls[kind] = ttl
+	}
+	for kind, ttl := range ttls {
+		c.ttls[kind] = ttl
+	}
+	return c, nil
+}
+
+func (c *Cache) Path() string {
+	return c.path
+}
+
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// TTL returns how long entries of the given kind stay fresh. Kinds without any TTL are not cached.
+func (c *Cache) TTL(kind string) time.Duration {
+	return c.ttls[kind]
+}
+
+// Get decodes the fresh entry stored for key into target. It reports false when there is no such entry.
+func (c *Cache) Get(ctx context.Context, kind string, key string, target interface{}) (bool, error) {
+	ttl := c.TTL(kind)
+	if ttl <= 0 {
+		return false, nil
+	}
+
+	var value []byte
+	var storedAt int64
+	err := c.db.QueryRowContext(ctx, "SELECT value, stored_at FROM entries WHERE kind = ? AND key = ?", kind, key).Scan(&value, &storedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	if time.Since(time.Unix(storedAt, 0)) > ttl {
+		return false, nil
+	}
+
+	err = json.Unmarshal(value, target)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode cache entry: %w", err)
+	}
+	return true, nil
+}
+
+// Put stores value for key, replacing any previous entry.
+func (c *Cache) Put(ctx context.Context, kind string, key string, value interface{}) error {
+	if c.TTL(kind) <= 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	_, err = c.db.ExecContext(ctx,
+		"INSERT INTO entries (kind, key, value, stored_at) VALUES (?, ?, ?, ?) ON CONFLICT (kind, key) DO UPDATE SET value = excluded.value, stored_at = excluded.stored_at",
+		kind, key, encoded, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Stats describes the entries of every kind of entity.
+func (c *Cache) Stats(ctx context.Context) ([]KindStats, error) {
+	var stats []KindStats
+	for _, kind := range Kinds {
+		entry := KindStats{Kind: kind, TTL: c.TTL(kind)}
+		var oldest sql.NullInt64
+		err := c.db.QueryRowContext(ctx,
+			"SELECT COUNT(*), COALESCE(SUM(LENGTH(value)), 0), MIN(stored_at), COALESCE(SUM(stored_at < ?), 0) FROM entries WHERE kind = ?",
+			time.Now().Add(-entry.TTL).Unix(), kind,
+		).Scan(&entry.Entries, &entry.Bytes, &oldest, &entry.Expired)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute cache statistics: %w", err)
+		}
+		if oldest.Valid {
+			entry.Oldest = time.Unix(oldest.Int64, 0)
+		}
+		stats = append(stats, entry)
+	}
+	return stats, nil
+}
+
+// Clear removes the entries of the given kind, or every entry when kind is empty. With expiredOnly, only
+// entries past their TTL are removed. It returns the number of removed entries.
+func (c *Cache) Clear(ctx context.Context, kind string, expiredOnly bool) (int64, error) {
+	var kinds = Kinds
+	if kind != "" {
+		kinds = []string{kind}
+	}
+
+	var removed int64
+	for _, k := range kinds {
+		var result sql.Result
+		var err error
+		if expiredOnly {
+			result, err = c.db.ExecContext(ctx, "DELETE FROM entries WHERE kind = ? AND stored_at < ?", k, time.Now().Add(-c.TTL(k)).Unix())
+		} else {
+			result, err = c.db.ExecContext(ctx, "DELETE FROM entries WHERE kind = ?", k)
+		}
+		if err != nil {
+			return removed, fmt.Errorf("failed to clear cache: %w", err)
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return removed, fmt.Errorf("failed to clear cache: %w", err)
+		}
+		removed += count
+	}
+	return removed, nil
+}
@@ -1,14 +1,56 @@
 package main
 
 type Config struct {
-	Username                  string   `json:"username"`
-	Password                  string   `json:"password"`
-	TotpSecretKey             string   `json:"totp_secret_key"`
-	WhatsAppNotificationGroup string   `json:"whatsapp_notification_group"`
-	WhatsAppBotGroups         []string `json:"whatsapp_bot_groups"`
+	PreferredMFAFactor        string    `json:"preferred_mfa_factor"`
+	WhatsAppNotificationGroup string    `json:"whatsapp_notification_group"`
+	WhatsAppBotGroups         []string  `json:"whatsapp_bot_groups"`
+	WhatsAppAdminGroup        string    `json:"whatsapp_admin_group"`
+	Endpoints                 Endpoints `json:"endpoints"`
+	HTTP                      HTTP      `json:"http"`
+	Cache                     Cache     `json:"cache"`
+	// Zone is the department, region or structures searched, e.g. "departement:92", "region:11" or
+	// "structures:97,1001".
+	Zone string `json:"zone"`
+	// ActivityKinds replaces the SAMU and BSPP kinds of activities summarized by commands and the bot.
+	ActivityKinds []ActivityKind `json:"activity_kinds"`
 }
 
-type AuthTicket struct {
-	CookieName  string `json:"name"`
-	CookieValue string `json:"value"`
+// ActivityKind is a family of activities summarized together, e.g. first aid posts.
+type ActivityKind struct {
+	Name            string `json:"name"`
+	Label           string `json:"label"`
+	ActionIDs       []int  `json:"action_ids"`
+	TypeActiviteIDs []int  `json:"type_activite_ids"`
+	// Trigger is the bot command replying with the summary of the kind, e.g. "dps" for "!dps".
+	Trigger string `json:"trigger"`
+	// Title names the kind in the daily notification, e.g. "du réseau de secours".
+	Title string `json:"title"`
+}
+
+// Cache tunes the on-disk cache of Pegass responses.
+type Cache struct {
+	Disabled bool `json:"disabled"`
+	// TTLMinutes overrides how long entries stay fresh, by kind (users, phones, trainings, structures, roles,
+	// activities). Zero disables caching of a kind.
+	TTLMinutes map[string]int `json:"ttl_minutes"`
+}
+
+// HTTP tunes timeouts, retries, rate limiting and concurrency of requests to Okta and Pegass. Zero values keep the defaults.
+type HTTP struct {
+	TimeoutSeconds    int     `json:"timeout_seconds"`
+	MaxRetries        *int    `json:"max_retries"`
+	RetryBaseDelayMs  int     `json:"retry_base_delay_ms"`
+	RetryMaxDelayMs   int     `json:"retry_max_delay_ms"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	// Concurrency is the number of Pegass lookups run in parallel when summarizing activities.
+	Concurrency int `json:"concurrency"`
+}
+
+// Endpoints allows targeting another Okta or Pegass instance, e.g. a staging environment or a local
+// fake server. Empty values fall back to the production endpoints.
+type Endpoints struct {
+	OktaBaseURL   string `json:"okta_base_url"`
+	PegassBaseURL string `json:"pegass_base_url"`
+	OktaAppPath   string `json:"okta_app_path"`
+	SAMLACSPath   string `json:"saml_acs_path"`
 }
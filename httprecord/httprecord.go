@@ -0,0 +1,356 @@
+package httprecord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveHeaders are replaced before an exchange is written to disk.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Location"}
+
+// sensitiveParameters are redacted wherever they appear: query strings, form and JSON bodies.
+var sensitiveParameters = map[string]bool{
+	"username":     true,
+	"password":     true,
+	"passCode":     true,
+	"token":        true,
+	"stateToken":   true,
+	"sessionToken": true,
+	"SAMLResponse": true,
+}
+
+// identityFields are redacted from the user profile embedded in Okta authentication responses.
+var identityFields = []string{"login", "firstName", "lastName"}
+
+// Exchange is a recorded request/response pair, as stored on disk.
+type Exchange struct {
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	RequestHeaders  http.Header `json:"request_headers"`
+	RequestBody     string      `json:"request_body,omitempty"`
+	StatusCode      int         `json:"status_code"`
+	ResponseHeaders http.Header `json:"response_headers"`
+	ResponseBody    string      `json:"response_body"`
+}
+
+// Recorder is a http.RoundTripper saving every exchange, with secrets redacted, to a directory.
+type Recorder struct {
+	dir     string
+	next    http.RoundTripper
+	mutex   sync.Mutex
+	counter int
+}
+
+func NewRecorder(dir string, next http.RoundTripper) (*Recorder, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{dir: dir, next: next}, nil
+}
+
+func (r *Recorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if request.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		request.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	response, err := r.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	exchange := Exchange{
+		Method:          request.Method,
+		URL:             redactURL(request.URL),
+		RequestHeaders:  redactHeaders(request.Header),
+		RequestBody:     redactBody(requestBody),
+		StatusCode:      response.StatusCode,
+		ResponseHeaders: redactHeaders(response.Header),
+		ResponseBody:    redactBody(responseBody),
+	}
+	err = r.save(exchange, request.URL)
+	if err != nil {
+		log.Warnf("failed to record HTTP exchange: %s", err)
+	}
+
+	return response, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+func (r *Recorder) save(exchange Exchange, u *url.URL) error {
+	r.mutex.Lock()
+	r.counter++
+	counter := r.counter
+	r.mutex.Unlock()
+
+	name := unsafeFileChars.ReplaceAllString(strings.Trim(u.Path, "/"), "-")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	fileName := filepath.Join(r.dir, fmt.Sprintf("%04d-%s-%s.json", counter, exchange.Method, name))
+
+	content := new(bytes.Buffer)
+	encoder := json.NewEncoder(content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(exchange)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, content.Bytes(), 0600)
+}
+
+// Replayer is a http.RoundTripper answering requests from the exchanges saved by a Recorder. Requests are
+// matched on method and URL, then on method and path only. Identical requests are answered in recording
+// order, the last recorded response being reused once they have all been served.
+type Replayer struct {
+	mutex      sync.Mutex
+	byURL      map[string][]Exchange
+	byPath     map[string][]Exchange
+	servedURL  map[string]int
+	servedPath map[string]int
+}
+
+func NewReplayer(dir string) (*Replayer, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no recording found in '%s'", dir)
+	}
+	sort.Strings(files)
+
+	r := &Replayer{
+		byURL:      make(map[string][]Exchange),
+		byPath:     make(map[string][]Exchange),
+		servedURL:  make(map[string]int),
+		servedPath: make(map[string]int),
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var exchange Exchange
+		err = json.Unmarshal(content, &exchange)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse recording '%s': %w", file, err)
+		}
+		u, err := url.Parse(exchange.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse URL of recording '%s': %w", file, err)
+		}
+		urlKey := exchange.Method + " " + exchange.URL
+		pathKey := exchange.Method + " " + u.Path
+		r.byURL[urlKey] = append(r.byURL[urlKey], exchange)
+		r.byPath[pathKey] = append(r.byPath[pathKey], exchange)
+	}
+	return r, nil
+}
+
+func (r *Replayer) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Body != nil {
+		request.Body.Close()
+	}
+
+	exchange, ok := r.find(request)
+	if !ok {
+		return nil, errors.New("no recorded response for " + request.Method + " " + redactURL(request.URL))
+	}
+
+	headers := exchange.ResponseHeaders.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.StatusCode, http.StatusText(exchange.StatusCode)),
+		StatusCode:    exchange.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          io.NopCloser(strings.NewReader(exchange.ResponseBody)),
+		ContentLength: int64(len(exchange.ResponseBody)),
+		Request:       request,
+	}, nil
+}
+
+func (r *Replayer) find(request *http.Request) (Exchange, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	urlKey := request.Method + " " + redactURL(request.URL)
+	if exchanges, ok := r.byURL[urlKey]; ok {
+		return next(exchanges, r.servedURL, urlKey), true
+	}
+	pathKey := request.Method + " " + request.URL.Path
+	if exchanges, ok := r.byPath[pathKey]; ok {
+		return next(exchanges, r.servedPath, pathKey), true
+	}
+	return Exchange{}, false
+}
+
+func next(exchanges []Exchange, served map[string]int, key string) Exchange {
+	idx := served[key]
+	if idx >= len(exchanges) {
+		idx = len(exchanges) - 1
+	}
+	served[key] = idx + 1
+	return exchanges[idx]
+}
+
+func redactURL(u *url.URL) string {
+	redactedURL := *u
+	query := redactedURL.Query()
+	for key := range query {
+		if sensitiveParameters[key] {
+			query.Set(key, redacted)
+		}
+	}
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.String()
+}
+
+// redactHeaders hides header values carrying credentials. Cookie names and redirection paths are kept, so
+// that a replayed response still sets the same cookies and redirects to the same page.
+func redactHeaders(headers http.Header) http.Header {
+	redactedHeaders := headers.Clone()
+	for _, name := range sensitiveHeaders {
+		values := redactedHeaders.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		redactedHeaders.Del(name)
+		for _, value := range values {
+			switch name {
+			case "Authorization":
+				redactedHeaders.Add(name, redacted)
+			case "Location":
+				redactedHeaders.Add(name, redactLocation(value))
+			default:
+				redactedHeaders.Add(name, redactCookieValues(value))
+			}
+		}
+	}
+	return redactedHeaders
+}
+
+// redactLocation hides the tokens of a redirection, e.g. the Okta session token, or all of it when it cannot be
+// parsed.
+func redactLocation(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return redacted
+	}
+	return redactURL(u)
+}
+
+var cookieValue = regexp.MustCompile(`([^=;,\s]+)=[^;,]*`)
+
+func redactCookieValues(header string) string {
+	return cookieValue.ReplaceAllStringFunc(header, func(pair string) string {
+		name := pair[:strings.Index(pair, "=")]
+		switch strings.ToLower(name) {
+		case "path", "domain", "expires", "max-age", "samesite":
+			return pair
+		}
+		return name + "=" + redacted
+	})
+}
+
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var value interface{}
+	if json.Unmarshal(body, &value) == nil {
+		redactOktaUser(value)
+		redactedBody, err := json.Marshal(redactJSON(value))
+		if err == nil {
+			return string(redactedBody)
+		}
+	}
+
+	if form, err := url.ParseQuery(string(body)); err == nil && len(form) > 0 && !bytes.ContainsAny(body, " \n<{") {
+		for key := range form {
+			if sensitiveParameters[key] {
+				form.Set(key, redacted)
+			}
+		}
+		return form.Encode()
+	}
+
+	return redactHTMLInputs(string(body))
+}
+
+func redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if sensitiveParameters[key] {
+				v[key] = redacted
+			} else {
+				v[key] = redactJSON(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactJSON(child)
+		}
+	}
+	return value
+}
+
+// redactOktaUser hides who logged in, as told by '_embedded.user.profile' in Okta authentication responses.
+func redactOktaUser(value interface{}) {
+	root, _ := value.(map[string]interface{})
+	embedded, _ := root["_embedded"].(map[string]interface{})
+	user, _ := embedded["user"].(map[string]interface{})
+	profile, _ := user["profile"].(map[string]interface{})
+	for _, field := range identityFields {
+		if _, ok := profile[field]; ok {
+			profile[field] = redacted
+		}
+	}
+}
+
+var samlInput = regexp.MustCompile(`(name="SAMLResponse"[^>]*value=")[^"]*(")`)
+
+// redactHTMLInputs hides the SAML assertion embedded in the Okta login form.
+func redactHTMLInputs(body string) string {
+	return samlInput.ReplaceAllString(body, "${1}"+redacted+"${2}")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/lint"
+	"gopkg.in/urfave/cli.v1"
+	"os"
+	"strings"
+)
+
+const LINT_RULES_FILE = "lint-rules.json"
+
+// loadLintRules reads the crew composition rules from 'lint-rules.json', next to 'config.json', falling back to
+// lint.DEFAULT_RULES when the file does not exist.
+func loadLintRules() (lint.RuleSet, error) {
+	rules, err := lint.Load(profilePath(LINT_RULES_FILE))
+	if errors.Is(err, os.ErrNotExist) {
+		return lint.DEFAULT_RULES, nil
+	} else if err != nil {
+		return rules, fmt.Errorf("invalid lint rules in '%s': %w", profilePath(LINT_RULES_FILE), err)
+	}
+	return rules, nil
+}
+
+var lintRulesCommand = cli.Command{
+	Name:  "lint-rules",
+	Usage: "Check or print the crew composition rules linting summaries",
+	Subcommands: []cli.Command{
+		{
+			Name:      "validate",
+			Usage:     fmt.Sprintf("Check a rules file, %s by default", LINT_RULES_FILE),
+			ArgsUsage: "[file]",
+			Action: func(c *cli.Context) error {
+				path := c.Args().Get(0)
+				if path == "" {
+					path = profilePath(LINT_RULES_FILE)
+				}
+				rules, err := lint.Load(path)
+				if err != nil {
+					// Validation reports one problem per line
+					for _, problem := range strings.Split(err.Error(), "\n") {
+						fmt.Fprintf(os.Stderr, "%s: %s\n", path, problem)
+					}
+					return fmt.Errorf("invalid lint rules in '%s'", path)
+				}
+				fmt.Printf("%s: %d rules, %d role sets, OK\n", path, len(rules.Rules), len(rules.RoleSets))
+				return nil
+			},
+		},
+		{
+			Name:  "show",
+			Usage: fmt.Sprintf("Print the rules in use, from %s or the default ones, e.g. to start a new rules file", LINT_RULES_FILE),
+			Action: func(c *cli.Context) error {
+				rules, err := loadLintRules()
+				if err != nil {
+					return err
+				}
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(rules)
+			},
+		},
+	},
+}
@@ -0,0 +1,299 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"github.com/fabien-chebel/pegass-cli/summary"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Volunteer is what rules know about a volunteer registered to a seance.
+type Volunteer struct {
+	// Role is the Pegass code of the role the volunteer registered as, e.g. "219" for PSE2.
+	Role  string
+	Minor bool
+	// FormerFirstResponder is set when the volunteer was trained as PSE1 or PSE2 but did not attend the yearly
+	// refresher. It is only looked up when a rule needs it, see RuleSet.NeedsTrainings.
+	FormerFirstResponder bool
+}
+
+// Rule bounds how many registered volunteers match its role set and filters. A finding is raised when the
+// count is out of bounds.
+type Rule struct {
+	// Name identifies the rule, and is the code of its findings.
+	Name string `json:"name"`
+	// RoleSet names the roles counted, among the role sets of the RuleSet. Every role is counted when empty.
+	RoleSet string `json:"role_set,omitempty"`
+	// Minor and FormerFirstResponder only count minors, or former first responders, when set.
+	Minor                bool `json:"minor,omitempty"`
+	FormerFirstResponder bool `json:"former_first_responder,omitempty"`
+	Min                  *int `json:"min,omitempty"`
+	Max                  *int `json:"max,omitempty"`
+	// ActivityTypes restricts the rule to some Pegass activity types, e.g. 10114 for dispatch. The rule applies
+	// to every activity when empty.
+	ActivityTypes []int `json:"activity_types,omitempty"`
+	// Severity is one of summary.SEVERITIES, summary.SEVERITY_WARNING when empty.
+	Severity string `json:"severity,omitempty"`
+	// Message is a text/template given the count of volunteers and the bounds, e.g. "{{.Count}} PSE1 (max {{.Max}})".
+	Message string `json:"message"`
+}
+
+// RuleSet lists crew composition rules, and the role sets they refer to.
+type RuleSet struct {
+	// RoleSets groups Pegass role codes under a name, e.g. "pse2": ["219"].
+	RoleSets map[string][]string `json:"role_sets"`
+	Rules    []Rule              `json:"rules"`
+}
+
+// messageData is given to message templates.
+type messageData struct {
+	Count int
+	Min   int
+	Max   int
+}
+
+func bound(value int) *int {
+	return &value
+}
+
+// Activity types of the first aid network.
+const (
+	ACTIVITY_TYPE_RESEAU_15 = 10115
+	ACTIVITY_TYPE_RESEAU_18 = 10116
+)
+
+// Role sets summaries describe crews with, e.g. to show the crew chief or count dispatchers. Rules files may
+// redefine them, those of DEFAULT_RULES being used otherwise.
+const (
+	ROLE_SET_CHIEFS                = "chiefs"
+	ROLE_SET_DRIVERS               = "drivers"
+	ROLE_SET_PSE1                  = "pse1"
+	ROLE_SET_PSE2                  = "pse2"
+	ROLE_SET_TRAINEES              = "trainees"
+	ROLE_SET_DISPATCHERS           = "dispatchers"
+	ROLE_SET_DISPATCHER_EVALUATORS = "dispatcher_evaluators"
+	ROLE_SET_RADIO_OPERATORS       = "radio_operators"
+)
+
+// DEFAULT_RULES check ambulance crews: minors, at most one PSE1, at least one PSE2, and no trainee who used to be
+// a first responder without attending refreshers.
+var DEFAULT_RULES = RuleSet{
+	RoleSets: map[string][]string{
+		ROLE_SET_CHIEFS:                {redcross.ROLE_CHIEF_NETWORK, redcross.ROLE_CHIEF_BSPP},
+		ROLE_SET_DRIVERS:               {redcross.ROLE_DRIVER},
+		ROLE_SET_PSE1:                  {redcross.ROLE_PSE1},
+		ROLE_SET_PSE2:                  {redcross.ROLE_PSE2},
+		ROLE_SET_TRAINEES:              {redcross.ROLE_PARTICIPANT},
+		ROLE_SET_DISPATCHERS:           {redcross.ROLE_ARS, redcross.ROLE_ARS_EVALUATOR},
+		ROLE_SET_DISPATCHER_EVALUATORS: {redcross.ROLE_ARS_EVALUATOR},
+		ROLE_SET_RADIO_OPERATORS:       {redcross.ROLE_RADIO_OPERATOR},
+	},
+	Rules: []Rule{
+		{
+			Name:    summary.FINDING_MINORS,
+			Minor:   true,
+			Max:     bound(0),
+			Message: "{{.Count}} 🔞",
+		},
+		{
+			Name:          summary.FINDING_TOO_MANY_PSE1,
+			RoleSet:       ROLE_SET_PSE1,
+			Max:           bound(1),
+			ActivityTypes: []int{ACTIVITY_TYPE_RESEAU_15, ACTIVITY_TYPE_RESEAU_18},
+			Message:       "{{.Count}} PSE1 (max {{.Max}})",
+		},
+		{
+			Name:          summary.FINDING_NO_PSE2,
+			RoleSet:       ROLE_SET_PSE2,
+			Min:           bound(1),
+			ActivityTypes: []int{ACTIVITY_TYPE_RESEAU_15, ACTIVITY_TYPE_RESEAU_18},
+			Message:       "Aucun PSE2",
+		},
+		{
+			Name:                 summary.FINDING_FORMER_FIRST_RESPONDER,
+			RoleSet:              ROLE_SET_TRAINEES,
+			FormerFirstResponder: true,
+			Max:                  bound(0),
+			ActivityTypes:        []int{ACTIVITY_TYPE_RESEAU_15, ACTIVITY_TYPE_RESEAU_18},
+			Message:              "Observateur PSE non-recyclé",
+		},
+	},
+}
+
+// Load reads and validates the rules of a JSON file.
+func Load(path string) (RuleSet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return RuleSet{}, fmt.Errorf("failed to read lint rules: %w", err)
+	}
+	return Parse(content)
+}
+
+// Parse decodes and validates JSON rules. Unknown fields are rejected, as they are most likely typos.
+func Parse(content []byte) (RuleSet, error) {
+	var rules RuleSet
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&rules)
+	if err != nil {
+		return rules, fmt.Errorf("failed to parse lint rules: %w", err)
+	}
+	return rules, rules.Validate()
+}
+
+// Validate checks every rule, and reports all the errors found.
+func (r RuleSet) Validate() error {
+	var errs []error
+	names := make(map[string]bool)
+	for i, rule := range r.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			errs = append(errs, fmt.Errorf("rule %s has no name", name))
+		} else if names[name] {
+			errs = append(errs, fmt.Errorf("rule '%s' is defined twice", name))
+		}
+		names[rule.Name] = true
+
+		if _, ok := r.RoleSets[rule.RoleSet]; rule.RoleSet != "" && !ok {
+			errs = append(errs, fmt.Errorf("rule '%s' refers to unknown role set '%s'", name, rule.RoleSet))
+		}
+		if rule.Min == nil && rule.Max == nil {
+			errs = append(errs, fmt.Errorf("rule '%s' has neither min nor max", name))
+		}
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			errs = append(errs, fmt.Errorf("rule '%s' has a min (%d) greater than its max (%d)", name, *rule.Min, *rule.Max))
+		}
+		if rule.Severity != "" && !slices.Contains(summary.SEVERITIES, rule.Severity) {
+			errs = append(errs, fmt.Errorf("rule '%s' has unknown severity '%s' (expected one of: %s)", name, rule.Severity, strings.Join(summary.SEVERITIES, ", ")))
+		}
+		if rule.Message == "" {
+			errs = append(errs, fmt.Errorf("rule '%s' has no message", name))
+		} else if _, err := rule.message(0); err != nil {
+			errs = append(errs, fmt.Errorf("rule '%s' has an invalid message: %w", name, err))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.RoleSets)) {
+		if len(r.RoleSets[name]) == 0 {
+			errs = append(errs, fmt.Errorf("role set '%s' is empty", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (rule Rule) message(count int) (string, error) {
+	tmpl, err := template.New(rule.Name).Option("missingkey=error").Parse(rule.Message)
+	if err != nil {
+		return "", err
+	}
+	var data = messageData{Count: count}
+	if rule.Min != nil {
+		data.Min = *rule.Min
+	}
+	if rule.Max != nil {
+		data.Max = *rule.Max
+	}
+	var buffer strings.Builder
+	err = tmpl.Execute(&buffer, data)
+	return buffer.String(), err
+}
+
+func (rule Rule) appliesTo(activityType int) bool {
+	return len(rule.ActivityTypes) == 0 || slices.Contains(rule.ActivityTypes, activityType)
+}
+
+// Roles lists the role codes of a role set, those of DEFAULT_RULES when the rule set does not define it.
+func (r RuleSet) Roles(roleSet string) []string {
+	roles, ok := r.RoleSets[roleSet]
+	if !ok {
+		return DEFAULT_RULES.RoleSets[roleSet]
+	}
+	return roles
+}
+
+// Includes reports whether a role belongs to a role set, see Roles.
+func (r RuleSet) Includes(roleSet string, role string) bool {
+	return slices.Contains(r.Roles(roleSet), role)
+}
+
+// Covers reports whether a role belongs to any role set, of the rule set or of DEFAULT_RULES.
+func (r RuleSet) Covers(role string) bool {
+	for roleSet := range r.RoleSets {
+		if r.Includes(roleSet, role) {
+			return true
+		}
+	}
+	for roleSet := range DEFAULT_RULES.RoleSets {
+		if r.Includes(roleSet, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count counts the volunteers of a crew whose role belongs to a role set, see Roles.
+func (r RuleSet) Count(roleSet string, crew []Volunteer) int {
+	var count int
+	for _, volunteer := range crew {
+		if r.Includes(roleSet, volunteer.Role) {
+			count++
+		}
+	}
+	return count
+}
+
+func (r RuleSet) counts(rule Rule, volunteer Volunteer) bool {
+	if rule.RoleSet != "" && !r.Includes(rule.RoleSet, volunteer.Role) {
+		return false
+	}
+	return (!rule.Minor || volunteer.Minor) && (!rule.FormerFirstResponder || volunteer.FormerFirstResponder)
+}
+
+// NeedsTrainings reports whether a rule needs to know whether a volunteer of the given role is a former
+// first responder, which requires fetching their trainings.
+func (r RuleSet) NeedsTrainings(role string) bool {
+	for _, rule := range r.Rules {
+		if rule.FormerFirstResponder && (rule.RoleSet == "" || r.Includes(rule.RoleSet, role)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check applies the rules scoped to the activity type to a crew, in order.
+func (r RuleSet) Check(activityType int, crew []Volunteer) ([]summary.Finding, error) {
+	var findings []summary.Finding
+	for _, rule := range r.Rules {
+		if !rule.appliesTo(activityType) {
+			continue
+		}
+
+		var count int
+		for _, volunteer := range crew {
+			if r.counts(rule, volunteer) {
+				count++
+			}
+		}
+		if (rule.Min == nil || count >= *rule.Min) && (rule.Max == nil || count <= *rule.Max) {
+			continue
+		}
+
+		message, err := rule.message(count)
+		if err != nil {
+			return findings, fmt.Errorf("failed to format message of rule '%s': %w", rule.Name, err)
+		}
+		severity := rule.Severity
+		if severity == "" {
+			severity = summary.SEVERITY_WARNING
+		}
+		findings = append(findings, summary.Finding{Code: rule.Name, Severity: severity, Message: message, Count: count})
+	}
+	return findings, nil
+}
@@ -0,0 +1,173 @@
+package lint
+
+import (
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"github.com/fabien-chebel/pegass-cli/summary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultRulesCheck(t *testing.T) {
+	chief := Volunteer{Role: redcross.ROLE_CHIEF_NETWORK}
+	pse1 := Volunteer{Role: redcross.ROLE_PSE1}
+	pse2 := Volunteer{Role: redcross.ROLE_PSE2}
+
+	tests := []struct {
+		name         string
+		activityType int
+		crew         []Volunteer
+		expected     []summary.Finding
+	}{
+		{
+			name:         "complete crew",
+			activityType: ACTIVITY_TYPE_RESEAU_15,
+			crew:         []Volunteer{chief, pse2, pse1},
+		},
+		{
+			name:         "minor",
+			activityType: ACTIVITY_TYPE_RESEAU_15,
+			crew:         []Volunteer{chief, pse2, {Role: redcross.ROLE_PSE1, Minor: true}},
+			expected: []summary.Finding{
+				{Code: summary.FINDING_MINORS, Severity: summary.SEVERITY_WARNING, Message: "1 🔞", Count: 1},
+			},
+		},
+		{
+			name:         "two PSE1 and no PSE2",
+			activityType: ACTIVITY_TYPE_RESEAU_18,
+			crew:         []Volunteer{chief, pse1, pse1},
+			expected: []summary.Finding{
+				{Code: summary.FINDING_TOO_MANY_PSE1, Severity: summary.SEVERITY_WARNING, Message: "2 PSE1 (max 1)", Count: 2},
+				{Code: summary.FINDING_NO_PSE2, Severity: summary.SEVERITY_WARNING, Message: "Aucun PSE2", Count: 0},
+			},
+		},
+		{
+			name:         "former first responder",
+			activityType: ACTIVITY_TYPE_RESEAU_15,
+			crew:         []Volunteer{chief, pse2, {Role: redcross.ROLE_PARTICIPANT, FormerFirstResponder: true}},
+			expected: []summary.Finding{
+				{Code: summary.FINDING_FORMER_FIRST_RESPONDER, Severity: summary.SEVERITY_WARNING, Message: "Observateur PSE non-recyclé", Count: 1},
+			},
+		},
+		{
+			name:         "dispatch is only checked for minors",
+			activityType: 10114,
+			crew:         []Volunteer{{Role: redcross.ROLE_ARS, Minor: true}, pse1, pse1},
+			expected: []summary.Finding{
+				{Code: summary.FINDING_MINORS, Severity: summary.SEVERITY_WARNING, Message: "1 🔞", Count: 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings, err := DEFAULT_RULES.Check(test.activityType, test.crew)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(findings, test.expected) {
+				t.Errorf("expected findings %+v, got %+v", test.expected, findings)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errors  []string
+	}{
+		{
+			name:    "valid rules",
+			content: `{"role_sets": {"ars": ["227"]}, "rules": [{"name": "ars", "role_set": "ars", "min": 1, "severity": "error", "message": "{{.Count}} ARS (min {{.Min}})"}]}`,
+		},
+		{
+			name:    "unknown field",
+			content: `{"rules": [{"name": "minors", "minor": true, "maximum": 0, "message": "mineurs"}]}`,
+			errors:  []string{"unknown field"},
+		},
+		{
+			name:    "every error is reported",
+			content: `{"role_sets": {"empty": []}, "rules": [{"role_set": "ars", "min": 2, "max": 1, "severity": "fatal", "message": "{{.Total}}"}, {"name": "x", "message": "x"}, {"name": "x", "min": 0, "message": "x"}]}`,
+			errors: []string{
+				"rule #1 has no name",
+				"refers to unknown role set 'ars'",
+				"has a min (2) greater than its max (1)",
+				"unknown severity 'fatal'",
+				"rule '#1' has an invalid message",
+				"rule 'x' has neither min nor max",
+				"rule 'x' is defined twice",
+				"role set 'empty' is empty",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.content))
+			if len(test.errors) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors %q, got none", test.errors)
+			}
+			for _, expected := range test.errors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected error to contain '%s', got: %s", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRoleSetFallback(t *testing.T) {
+	rules := RuleSet{RoleSets: map[string][]string{ROLE_SET_DISPATCHERS: {redcross.ROLE_ARS}}}
+	crew := []Volunteer{
+		{Role: redcross.ROLE_ARS},
+		{Role: redcross.ROLE_ARS_EVALUATOR},
+		{Role: redcross.ROLE_RADIO_OPERATOR},
+	}
+
+	tests := []struct {
+		roleSet  string
+		expected int
+	}{
+		{roleSet: ROLE_SET_DISPATCHERS, expected: 1},
+		{roleSet: ROLE_SET_RADIO_OPERATORS, expected: 1},
+		{roleSet: ROLE_SET_TRAINEES, expected: 0},
+		{roleSet: "unknown", expected: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.roleSet, func(t *testing.T) {
+			if count := rules.Count(test.roleSet, crew); count != test.expected {
+				t.Errorf("expected %d volunteers in role set '%s', got %d", test.expected, test.roleSet, count)
+			}
+		})
+	}
+
+	if !rules.Covers(redcross.ROLE_ARS_EVALUATOR) {
+		t.Errorf("expected role %s to be covered by the default role sets", redcross.ROLE_ARS_EVALUATOR)
+	}
+	if rules.Covers(redcross.ROLE_DISPATCHER) {
+		t.Errorf("expected role %s not to be covered", redcross.ROLE_DISPATCHER)
+	}
+}
+
+func TestNeedsTrainings(t *testing.T) {
+	tests := []struct {
+		role     string
+		expected bool
+	}{
+		{role: redcross.ROLE_PARTICIPANT, expected: true},
+		{role: redcross.ROLE_PSE2, expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.role, func(t *testing.T) {
+			if needed := DEFAULT_RULES.NeedsTrainings(test.role); needed != test.expected {
+				t.Errorf("expected NeedsTrainings(%s) to be %t", test.role, test.expected)
+			}
+		})
+	}
+}
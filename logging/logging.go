@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strings"
+)
+
+// Log formats.
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+)
+
+var FORMATS = []string{FORMAT_TEXT, FORMAT_JSON}
+
+// CORRELATION_ID_FIELD is the log field holding the correlation ID of the command or bot message being handled.
+const CORRELATION_ID_FIELD = "correlation_id"
+
+// Setup writes logs to stdout in the given format, redacting secrets and phone numbers. Debug logs are
+// only written when verbose is set.
+func Setup(format string, verbose bool) error {
+	var formatter log.Formatter
+	switch format {
+	case FORMAT_TEXT, "":
+		formatter = &log.TextFormatter{}
+	case FORMAT_JSON:
+		formatter = &log.JSONFormatter{}
+	default:
+		return fmt.Errorf("unknown log format '%s' (expected one of: %s)", format, strings.Join(FORMATS, ", "))
+	}
+
+	log.SetOutput(os.Stdout)
+	log.SetFormatter(&redactingFormatter{next: formatter})
+	if verbose {
+		log.SetLevel(log.DebugLevel)
+	} else {
+		log.SetLevel(log.InfoLevel)
+	}
+	return nil
+}
+
+type correlationIDKey struct{}
+
+// NewCorrelationID returns a random identifier, tying together the logs of a command or bot message.
+func NewCorrelationID() string {
+	var id = make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// WithCorrelationID returns a copy of ctx carrying the given correlation ID.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID carried by ctx, if any.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// FromContext returns a logger adding the correlation ID carried by ctx, if any, to every entry.
+func FromContext(ctx context.Context) *log.Entry {
+	entry := log.NewEntry(log.StandardLogger())
+	if ctx == nil {
+		return entry
+	}
+	if id := CorrelationID(ctx); id != "" {
+		entry = entry.WithField(CORRELATION_ID_FIELD, id)
+	}
+	return entry.WithContext(ctx)
+}
+
+// redactingFormatter hides secrets and phone numbers from messages and fields before formatting an entry.
+type redactingFormatter struct {
+	next log.Formatter
+}
+
+func (f *redactingFormatter) Format(entry *log.Entry) ([]byte, error) {
+	redactedEntry := *entry
+	redactedEntry.Message = Redact(entry.Message)
+	redactedEntry.Data = make(log.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		redactedEntry.Data[key] = redactField(key, value)
+	}
+	return f.next.Format(&redactedEntry)
+}
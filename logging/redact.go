@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const REDACTED = "REDACTED"
+
+// sensitiveFieldNames are the log fields, matched case-insensitively, whose values are never logged.
+// Fields whose name contains one of sensitiveFieldParts are hidden as well.
+var sensitiveFieldNames = map[string]bool{
+	"code":        true,
+	"phone":       true,
+	"phonenumber": true,
+	"headers":     true,
+}
+
+var sensitiveFieldParts = []string{"password", "passcode", "token", "cookie", "secret", "totp", "authorization", "saml"}
+
+var redactions = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// Query strings and forms, e.g. ?sessionToken=...
+	{regexp.MustCompile(`(?i)\b(password|passCode|totp|totp_secret_key|token|stateToken|sessionToken|SAMLResponse|access_token|id_token)=[^&\s"',;]+`), "${1}=" + REDACTED},
+	// JSON documents
+	{regexp.MustCompile(`(?i)"(password|passCode|totp|totp_secret_key|token|stateToken|sessionToken|SAMLResponse)"\s*:\s*"[^"]*"`), `"${1}":"` + REDACTED + `"`},
+	// Headers, e.g. "Set-Cookie: ..." or a printed http.Header map
+	{regexp.MustCompile(`(?i)\b((?:set-)?cookie|authorization)(["']?\s*[:=]\s*\[?)[^\]\n]+`), "${1}${2}" + REDACTED},
+	// SAML assertion in the Okta login form
+	{regexp.MustCompile(`(name="SAMLResponse"[^>]*value=")[^"]*(")`), "${1}" + REDACTED + "${2}"},
+	// French phone numbers, e.g. 06 12 34 56 78 or +33 6 12 34 56 78
+	{regexp.MustCompile(`(?:\+33\s?|\b0033\s?|\b0)[1-9](?:[\s.-]?\d{2}){4}\b`), REDACTED},
+	// International phone numbers, and WhatsApp user IDs such as 33612345678@s.whatsapp.net
+	{regexp.MustCompile(`\+\d{10,14}\b`), REDACTED},
+	{regexp.MustCompile(`\b\d{10,15}(@s\.whatsapp\.net)`), REDACTED + "${1}"},
+	// NIVOLs, identifying volunteers, e.g. 01100009672H in /crf/rest/utilisateur/01100009672H or ?utilisateur=...
+	{regexp.MustCompile(`\b\d{8,12}[A-Z]\b`), REDACTED},
+}
+
+// Redact hides tokens, cookies, passwords, phone numbers and NIVOLs found in s.
+func Redact(s string) string {
+	for _, redaction := range redactions {
+		s = redaction.pattern.ReplaceAllString(s, redaction.replacement)
+	}
+	return s
+}
+
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	if sensitiveFieldNames[name] {
+		return true
+	}
+	for _, part := range sensitiveFieldParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactField(name string, value interface{}) interface{} {
+	if isSensitiveField(name) {
+		return REDACTED
+	}
+	switch v := value.(type) {
+	case string:
+		return Redact(v)
+	case error:
+		return Redact(v.Error())
+	case fmt.Stringer:
+		return Redact(v.String())
+	}
+	return value
+}
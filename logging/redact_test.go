@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{
+			name:     "session token in a query",
+			value:    "GET https://example.okta.com/login/sessionCookieRedirect?checkAccountSetupComplete=true&sessionToken=20111abc&redirectUrl=x",
+			expected: "GET https://example.okta.com/login/sessionCookieRedirect?checkAccountSetupComplete=true&sessionToken=REDACTED&redirectUrl=x",
+		},
+		{
+			name:     "TOTP code in a form",
+			value:    "passCode=123456&stateToken=00abc",
+			expected: "passCode=REDACTED&stateToken=REDACTED",
+		},
+		{
+			name:     "JSON credentials",
+			value:    `{"username":"jdoe","password":"hunter2","totp": "123456"}`,
+			expected: `{"username":"jdoe","password":"REDACTED","totp":"REDACTED"}`,
+		},
+		{
+			name:     "cookie header",
+			value:    "Set-Cookie: JSESSIONID=abc; Path=/",
+			expected: "Set-Cookie: REDACTED",
+		},
+		{
+			name:     "French phone numbers",
+			value:    "appeler le 06 12 34 56 78 ou le +33 6 12 34 56 78",
+			expected: "appeler le REDACTED ou le REDACTED",
+		},
+		{
+			name:     "WhatsApp user",
+			value:    "message from 33612345678@s.whatsapp.net",
+			expected: "message from REDACTED@s.whatsapp.net",
+		},
+		{
+			name:     "NIVOL in a path and a query",
+			value:    "/crf/rest/utilisateur/01100009672H?utilisateur=00000012345Z",
+			expected: "/crf/rest/utilisateur/REDACTED?utilisateur=REDACTED",
+		},
+		{
+			name:     "nothing sensitive",
+			value:    "fetched 12 activities for structure 97 on 2026-10-18",
+			expected: "fetched 12 activities for structure 97 on 2026-10-18",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if redacted := Redact(test.value); redacted != test.expected {
+				t.Errorf("expected '%s', got '%s'", test.expected, redacted)
+			}
+		})
+	}
+}
+
+func TestRedactField(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{name: "phone", value: "0612345678", expected: REDACTED},
+		{name: "sessionToken", value: "abc", expected: REDACTED},
+		{name: "nivol", value: "01100009672H", expected: REDACTED},
+		{name: "endpoint", value: "/crf/rest/utilisateur/{id}", expected: "/crf/rest/utilisateur/{id}"},
+		{name: "attempt", value: 2, expected: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if redacted := redactField(test.name, test.value); redacted != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, redacted)
+			}
+		})
+	}
+}
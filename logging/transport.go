@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"github.com/fabien-chebel/pegass-cli/metrics"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// Transport is a http.RoundTripper logging every request at debug level, along with the correlation ID
+// carried by its context. Only the host and the normalized path are logged: query strings and identifiers in
+// paths, such as NIVOLs, may identify volunteers.
+type Transport struct {
+	next http.RoundTripper
+}
+
+func NewTransport(next http.RoundTripper) *Transport {
+	return &Transport{next: next}
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	start := time.Now()
+	response, err := t.next.RoundTrip(request)
+
+	fields := log.Fields{
+		"method":   request.Method,
+		"host":     request.URL.Host,
+		"endpoint": metrics.NormalizeEndpoint(request.URL.Path),
+		"duration": time.Since(start),
+	}
+	if err != nil {
+		fields["error"] = err
+	} else {
+		fields["statusCode"] = response.StatusCode
+	}
+	FromContext(request.Context()).WithFields(fields).Debug("HTTP request sent")
+	return response, err
+}
@@ -1,61 +1,339 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/fabien-chebel/pegass-cli/httprecord"
+	"github.com/fabien-chebel/pegass-cli/logging"
+	"github.com/fabien-chebel/pegass-cli/metrics"
+	"github.com/fabien-chebel/pegass-cli/mockserver"
+	"github.com/fabien-chebel/pegass-cli/pegass"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"github.com/fabien-chebel/pegass-cli/transport"
 	"github.com/fabien-chebel/pegass-cli/whatsapp"
 	_ "github.com/glebarez/go-sqlite"
 	log "github.com/sirupsen/logrus"
 	"go.mau.fi/whatsmeow/types"
+	"golang.org/x/term"
 	"gopkg.in/urfave/cli.v1"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
 const APP_VERSION = "1.9.0"
 
-var pegassClient PegassClient
+// DEFAULT_BOT_COMMAND_TIMEOUT bounds the handling of a single bot command, so that a stuck Pegass call
+// cannot block the bot forever.
+const DEFAULT_BOT_COMMAND_TIMEOUT = 5 * time.Minute
 
-func initClient() (Config, error) {
+// METRICS_REFRESH_INTERVAL is how often the bot counts incomplete seances for its metrics.
+const METRICS_REFRESH_INTERVAL = 15 * time.Minute
+
+var pegassClient *pegass.PegassClient
+
+var preferredMFAFactor string
+var oktaBaseURL string
+var pegassBaseURL string
+var recordDir string
+var replayDir string
+var commandTimeout time.Duration
+var noCache bool
+var logFormat string
+var zoneFlag string
+
+func initClient(ctx context.Context) (Config, error) {
 	configData := parseConfig()
-	pegassClient = PegassClient{
-		Username:      configData.Username,
-		Password:      configData.Password,
-		TotpSecretKey: configData.TotpSecretKey,
+	err := loadClient(configData, nil)
+	if err != nil {
+		return configData, err
+	}
+	return configData, pegassClient.AuthenticateIfNecessaryContext(ctx)
+}
+
+// commandContext returns the context bounding a CLI command. It is cancelled on Ctrl-C or SIGTERM, and once
+// the --timeout delay has elapsed, if any.
+func commandContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(logging.WithCorrelationID(context.Background(), logging.NewCorrelationID()), os.Interrupt, syscall.SIGTERM)
+	if commandTimeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// newTransport returns the HTTP transport used to reach Pegass: requests are retried and rate limited, and
+// exchanges are recorded or replayed when asked to. Each attempt times out on its own; the returned timeout
+// bounds a whole request, retries included.
+func newTransport(httpConfig HTTP, m *metrics.Metrics) (http.RoundTripper, time.Duration, error) {
+	attemptTimeout := time.Duration(httpConfig.TimeoutSeconds) * time.Second
+	if recordDir != "" && replayDir != "" {
+		return nil, 0, errors.New("--record and --replay cannot be used together")
+	}
+	if replayDir != "" {
+		log.Infof("Replaying HTTP exchanges from '%s'", replayDir)
+		replayer, err := httprecord.NewReplayer(replayDir)
+		return replayer, attemptTimeout, err
+	}
+
+	// Count and log every attempt, retries included
+	var next http.RoundTripper = logging.NewTransport(metrics.NewTransport(transport.NewBaseTransport(), m))
+	if recordDir != "" {
+		log.Infof("Recording HTTP exchanges to '%s'", recordDir)
+		recorder, err := httprecord.NewRecorder(recordDir, next)
+		if err != nil {
+			return nil, 0, err
+		}
+		next = recorder
 	}
-	return configData, pegassClient.Authenticate()
+
+	options := transport.DefaultOptions()
+	if attemptTimeout > 0 {
+		options.AttemptTimeout = attemptTimeout
+	}
+	if httpConfig.MaxRetries != nil {
+		options.MaxRetries = *httpConfig.MaxRetries
+	}
+	if httpConfig.RetryBaseDelayMs > 0 {
+		options.BaseDelay = time.Duration(httpConfig.RetryBaseDelayMs) * time.Millisecond
+	}
+	if httpConfig.RetryMaxDelayMs > 0 {
+		options.MaxDelay = time.Duration(httpConfig.RetryMaxDelayMs) * time.Millisecond
+	}
+	if httpConfig.RequestsPerSecond > 0 {
+		options.RequestsPerSecond = httpConfig.RequestsPerSecond
+	}
+	return transport.New(next, options), options.Budget(), nil
 }
 
-func initLogs(verbose bool) {
-	log.SetOutput(os.Stdout)
-	if verbose {
-		log.SetLevel(log.DebugLevel)
+// loadClient configures the Pegass client with the secrets held in the vault, without authenticating.
+func loadClient(configData Config, m *metrics.Metrics, extraOptions ...pegass.Option) error {
+	err := checkNoPlaintextSecrets()
+	if err != nil {
+		return err
+	}
+	v, err := openVault()
+	if err != nil {
+		return fmt.Errorf("failed to open vault: %w", err)
+	}
+	secrets := v.Secrets()
+
+	endpoints := pegass.Endpoints{
+		OktaBaseURL:   configData.Endpoints.OktaBaseURL,
+		PegassBaseURL: configData.Endpoints.PegassBaseURL,
+		OktaAppPath:   configData.Endpoints.OktaAppPath,
+		SAMLACSPath:   configData.Endpoints.SAMLACSPath,
+	}
+	if oktaBaseURL != "" {
+		endpoints.OktaBaseURL = oktaBaseURL
+	}
+	if pegassBaseURL != "" {
+		endpoints.PegassBaseURL = pegassBaseURL
+	}
+
+	httpTransport, requestTimeout, err := newTransport(configData.HTTP, m)
+	if err != nil {
+		return err
+	}
+
+	zoneName := configData.Zone
+	if zoneFlag != "" {
+		zoneName = zoneFlag
+	}
+	// Left unset, the client picks its defaults, which differ for regulation statistics
+	var zone pegass.Zone
+	if zoneName != "" {
+		zone, err = pegass.ParseZone(zoneName)
+		if err != nil {
+			return err
+		}
+	}
+
+	var activityKinds []pegass.ActivityKind
+	for _, kind := range configData.ActivityKinds {
+		activityKinds = append(activityKinds, pegass.ActivityKind{
+			Name:            kind.Name,
+			Label:           kind.Label,
+			ActionIDs:       kind.ActionIDs,
+			TypeActiviteIDs: kind.TypeActiviteIDs,
+			Trigger:         kind.Trigger,
+			Title:           kind.Title,
+		})
+	}
+	err = pegass.ValidateActivityKinds(activityKinds)
+	if err != nil {
+		return fmt.Errorf("invalid activity_kinds in config.json: %w", err)
+	}
+
+	referenceData, err := loadReferenceData()
+	if err != nil {
+		return err
+	}
+
+	lintRules, err := loadLintRules()
+	if err != nil {
+		return err
+	}
+
+	mfaFactor := configData.PreferredMFAFactor
+	if preferredMFAFactor != "" {
+		mfaFactor = preferredMFAFactor
+	}
+
+	options := []pegass.Option{
+		pegass.WithCredentials(secrets.Username, secrets.Password, secrets.TotpSecretKey),
+		pegass.WithEndpoints(endpoints),
+		pegass.WithTransport(httpTransport),
+		pegass.WithTimeout(requestTimeout),
+		pegass.WithConcurrency(configData.HTTP.Concurrency),
+		pegass.WithMFAFactor(mfaFactor),
+		pegass.WithZone(zone),
+		pegass.WithActivityKinds(activityKinds),
+	}
+	if recordDir == "" && replayDir == "" {
+		// Recordings start with a login, so that they can be replayed, and replayed sessions carry redacted
+		// cookies, which must not overwrite the session saved in the vault
+		options = append(options, pegass.WithSessionStore(v))
+	}
+	options = append(options, referenceData.clientOptions()...)
+	options = append(options, pegass.WithLintRules(lintRules), pegass.WithMetrics(m))
+	if noCache || configData.Cache.Disabled || recordDir != "" || replayDir != "" {
+		// Recordings must reflect the requests actually sent to Pegass
+		log.Debug("cache is disabled")
+	} else if store, err := openCache(configData.Cache); err != nil {
+		log.Warnf("Pegass responses will not be cached: %s", err)
 	} else {
-		log.SetLevel(log.InfoLevel)
+		options = append(options, pegass.WithCache(store))
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		options = append(options, pegass.WithPrompt(promptLine))
 	}
+
+	pegassClient = pegass.New(append(options, extraOptions...)...)
+	return nil
 }
 
 func main() {
-	initLogs(os.Getenv("VERBOSE") != "")
+	_ = logging.Setup(logging.FORMAT_TEXT, os.Getenv("VERBOSE") != "")
 
 	app := cli.NewApp()
 	app.Name = "Pegass CLI"
 	app.Usage = "Interact with Red Cross's Pegass web app through the CLI"
 	app.Version = APP_VERSION
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:        "profile",
+			Usage:       "keep configuration, vault and WhatsApp device store in the profile directory ~/.config/pegass-cli/<profile>/",
+			EnvVar:      PROFILE_ENV,
+			Destination: &profileName,
+		},
+		cli.StringFlag{
+			Name:        "vault",
+			Usage:       "path to the encrypted vault holding Pegass secrets (default: vault.json in the profile directory)",
+			Destination: &vaultPath,
+		},
+		cli.StringFlag{
+			Name:        "vault-key-file",
+			Usage:       "read the vault passphrase from this file instead of prompting for it",
+			Destination: &vaultKeyFile,
+		},
+		cli.StringFlag{
+			Name:        "mfa-factor",
+			Usage:       fmt.Sprintf("MFA factor to try first (one of: %s)", strings.Join(pegass.MFA_FACTORS, ", ")),
+			Destination: &preferredMFAFactor,
+		},
+		cli.StringFlag{
+			Name:        "okta-url",
+			Usage:       "base URL of the Okta instance, overriding the configuration (default: " + pegass.DEFAULT_OKTA_BASE_URL + ")",
+			Destination: &oktaBaseURL,
+		},
+		cli.StringFlag{
+			Name:        "pegass-url",
+			Usage:       "base URL of the Pegass instance, overriding the configuration (default: " + pegass.DEFAULT_PEGASS_BASE_URL + ")",
+			Destination: &pegassBaseURL,
+		},
+		cli.StringFlag{
+			Name:        "zone",
+			Usage:       "department, region or structures to search, e.g. 'departement:92', 'region:11' or 'structures:97,1001' (default: " + pegass.DEFAULT_ZONE.String() + ", " + pegass.DEFAULT_STATS_ZONE.String() + " for regulationstats)",
+			EnvVar:      "PEGASS_ZONE",
+			Destination: &zoneFlag,
+		},
+		cli.StringFlag{
+			Name:        "record",
+			Usage:       "save every HTTP exchange with Okta and Pegass, with secrets redacted, to this directory",
+			Destination: &recordDir,
+		},
+		cli.StringFlag{
+			Name:        "replay",
+			Usage:       "answer HTTP requests from the exchanges previously recorded in this directory",
+			Destination: &replayDir,
+		},
+		cli.BoolFlag{
+			Name:        "no-cache",
+			Usage:       "always fetch fresh data from Pegass, ignoring the on-disk cache",
+			Destination: &noCache,
+		},
+		cli.StringFlag{
+			Name:        "log-format",
+			Usage:       fmt.Sprintf("format of the logs (one of: %s)", strings.Join(logging.FORMATS, ", ")),
+			EnvVar:      "PEGASS_LOG_FORMAT",
+			Value:       logging.FORMAT_TEXT,
+			Destination: &logFormat,
+		},
+		cli.DurationFlag{
+			Name:        "timeout",
+			Usage:       "abort the command if it does not complete within this delay, e.g. '5m' (default: no limit)",
+			Destination: &commandTimeout,
+		},
+	}
+
+	app.Before = func(c *cli.Context) error {
+		err := logging.Setup(logFormat, os.Getenv("VERBOSE") != "")
+		if err != nil {
+			return err
+		}
+		err = selectProfile()
+		if err != nil {
+			return err
+		}
+		if vaultPath == "" {
+			vaultPath = profilePath("vault.json")
+		}
+		return nil
+	}
 
 	app.Commands = []cli.Command{
+		vaultCommand,
+		profilesCommand,
+		cacheCommand,
+		associationsCommand,
+		activityOrderCommand,
+		lintRulesCommand,
+		rolesCommand,
+		structuresCommand,
+		activitiesCommand,
+		summaryCommand,
 		{
 			Name:  "login",
 			Usage: "Authenticate to Pegass",
 			Action: func(c *cli.Context) error {
-				_, err := initClient()
+				ctx, cancel := commandContext()
+				defer cancel()
+				err := loadClient(parseConfig(), nil)
 				if err != nil {
-					return nil
+					return err
 				}
-				err = pegassClient.Authenticate()
+				err = pegassClient.AuthenticateContext(ctx)
 				if err != nil {
 					return err
 				}
@@ -66,11 +344,13 @@ func main() {
 			Name:  "whoami",
 			Usage: "Get current user information",
 			Action: func(c *cli.Context) error {
-				_, err := initClient()
+				ctx, cancel := commandContext()
+				defer cancel()
+				_, err := initClient(ctx)
 				if err != nil {
 					return err
 				}
-				user, err := pegassClient.GetCurrentUser()
+				user, err := pegassClient.GetCurrentUserContext(ctx)
 				if err != nil {
 					return err
 				}
@@ -82,12 +362,14 @@ func main() {
 			Name:  "dispatchers",
 			Usage: "Get list of current dispatchers",
 			Action: func(c *cli.Context) error {
-				err := pegassClient.ReAuthenticate()
+				ctx, cancel := commandContext()
+				defer cancel()
+				_, err := initClient(ctx)
 				if err != nil {
 					return err
 				}
 
-				_, err = pegassClient.GetDispatchers()
+				_, err = pegassClient.GetDispatchersContext(ctx)
 				if err != nil {
 					return err
 				}
@@ -98,18 +380,20 @@ func main() {
 			Name:  "dispatcherstats",
 			Usage: "Get dispatcher stats",
 			Action: func(c *cli.Context) error {
-				err := pegassClient.ReAuthenticate()
+				ctx, cancel := commandContext()
+				defer cancel()
+				_, err := initClient(ctx)
 				if err != nil {
 					return err
 				}
 
-				dispatchers, err := pegassClient.GetDispatchers()
+				dispatchers, err := pegassClient.GetDispatchersContext(ctx)
 				if err != nil {
 					return err
 				}
 
 				for _, dispatcher := range dispatchers {
-					stats, err := pegassClient.GetStatsForUser(dispatcher.ID)
+					stats, err := pegassClient.GetStatsForUserContext(ctx, dispatcher.ID)
 					if err != nil {
 						return err
 					}
@@ -139,12 +423,14 @@ func main() {
 			Name:  "regulationstats",
 			Usage: "Export regulation stats",
 			Action: func(c *cli.Context) error {
-				err := pegassClient.ReAuthenticate()
+				ctx, cancel := commandContext()
+				defer cancel()
+				_, err := initClient(ctx)
 				if err != nil {
 					return err
 				}
 
-				statsByUser, err := pegassClient.GetActivityStats()
+				statsByUser, err := pegassClient.GetActivityStatsContext(ctx)
 				if err != nil {
 					return err
 				}
@@ -164,7 +450,7 @@ func main() {
 				}
 
 				for nivol, stats := range statsByUser {
-					details, err := pegassClient.GetUserDetails(nivol)
+					details, err := pegassClient.GetUserDetailsContext(ctx, nivol)
 					if err != nil {
 						log.Printf("failed to fetch user details for user '%s' ; %s", nivol, err)
 					}
@@ -185,21 +471,23 @@ func main() {
 			Action: func(c *cli.Context) error {
 				roleName := c.Args().Get(0)
 
-				err := pegassClient.ReAuthenticate()
+				ctx, cancel := commandContext()
+				defer cancel()
+				_, err := initClient(ctx)
 				if err != nil {
 					return err
 				}
 
-				role, err := pegassClient.FindRoleByName(roleName)
+				role, err := pegassClient.FindRoleByNameContext(ctx, roleName)
 				if err != nil {
 					return err
 				}
 
 				log.Printf("Found role {id: '%s', type: '%s', name: '%s'} for role name '%s'", role.ID, role.Type, role.Libelle, roleName)
 
-				users, err := pegassClient.GetUsersForRole(role)
+				users, err := pegassClient.GetUsersForRoleContext(ctx, role)
 
-				f, err := os.Create(fmt.Sprintf("user-export-92-%s-%s.csv", role.Type, role.ID))
+				f, err := os.Create(fmt.Sprintf("user-export-%s-%s-%s.csv", pegassClient.Zone().Slug(), role.Type, role.ID))
 				defer f.Close()
 				if err != nil {
 					return err
@@ -234,9 +522,22 @@ func main() {
 		},
 		{
 			Name:  "summarize-samu-activities",
-			Usage: "Fetch tomorrow's SAMU-related activities and send their status to WhatsApp",
+			Usage: "Fetch tomorrow's activities of a kind, SAMU by default, and send their status to WhatsApp",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "kind",
+					Usage: "kind of activities, as named in config.json",
+					Value: "samu",
+				},
+			},
 			Action: func(c *cli.Context) error {
-				conf, err := initClient()
+				ctx, cancel := commandContext()
+				defer cancel()
+				conf, err := initClient(ctx)
+				if err != nil {
+					return err
+				}
+				kind, err := pegassClient.ActivityKind(c.String("kind"))
 				if err != nil {
 					return err
 				}
@@ -249,22 +550,23 @@ func main() {
 				}
 
 				log.Info("Fetching activity summary for day ", day)
-				summary, err := pegassClient.FindActivitiesOnDay(day, SAMU, shouldCensorData)
+				summary, err := pegassClient.FindActivitiesOnDayContext(ctx, day, kind, shouldCensorData)
 				if err != nil {
 					return err
 				}
-				summary = fmt.Sprintf("Etat du réseau de secours de demain (%s):\n%s", day, summary)
+				summary = fmt.Sprintf("Etat %s de demain (%s):\n%s", kind.Headline(), day, summary)
 				log.Info(summary)
 
 				if conf.WhatsAppNotificationGroup == "" {
 					return fmt.Errorf("no WhatsApp group Id provided. Skipping WhatsApp notification")
 				}
 				jid, err := types.ParseJID(conf.WhatsAppNotificationGroup)
-				whatsAppClient := whatsapp.NewClient()
+				whatsAppClient := whatsapp.NewClient(profilePath("pegass.db"))
 				if err != nil {
 					return err
 				}
-				err = whatsAppClient.SendMessage(
+				err = whatsAppClient.SendMessageContext(
+					ctx,
 					summary,
 					jid,
 				)
@@ -272,12 +574,38 @@ func main() {
 				return err
 			},
 		},
+		{
+			Name:  "mock-server",
+			Usage: "Serve fake Okta and Pegass endpoints from JSON fixtures, for offline development and demos",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "listen",
+					Usage: "address to listen on",
+					Value: "localhost:8080",
+				},
+				cli.StringFlag{
+					Name:  "fixtures",
+					Usage: "directory of JSON fixtures mirroring Pegass '/crf/rest' paths (default: bundled fixtures)",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				fixtures := mockserver.DefaultFixtures()
+				if dir := c.String("fixtures"); dir != "" {
+					fixtures = os.DirFS(dir)
+				}
+
+				server := mockserver.NewServer(fixtures, pegass.DEFAULT_OKTA_APP_PATH, pegass.DEFAULT_SAML_ACS_PATH)
+				baseURL := "http://" + c.String("listen")
+				log.Infof("Mock server listening on %s. Run other commands with '--okta-url %s --pegass-url %s'", baseURL, baseURL, baseURL)
+				return http.ListenAndServe(c.String("listen"), server.Handler())
+			},
+		},
 		{
 			Name:  "register-chat-device",
 			Usage: "Register whats app device locally",
 			Action: func(c *cli.Context) error {
 				log.Infof("Starting what's app client")
-				whatsAppClient := whatsapp.NewClient()
+				whatsAppClient := whatsapp.NewClient(profilePath("pegass.db"))
 				log.Infof("Registering device")
 				err := whatsAppClient.RegisterDevice()
 				if err != nil {
@@ -290,23 +618,60 @@ func main() {
 		{
 			Name: "list-chat-groups",
 			Action: func(c *cli.Context) error {
-				whatsAppClient := whatsapp.NewClient()
+				whatsAppClient := whatsapp.NewClient(profilePath("pegass.db"))
 				return whatsAppClient.PrintGroupList()
 			},
 		},
 		{
 			Name: "start-bot",
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  "command-timeout",
+					Usage: "abort the handling of a bot command if it does not complete within this delay",
+					Value: DEFAULT_BOT_COMMAND_TIMEOUT,
+				},
+				cli.StringFlag{
+					Name:  "metrics-listen",
+					Usage: "serve Prometheus metrics on this address, e.g. 'localhost:9090' (disabled by default)",
+				},
+			},
 			Action: func(c *cli.Context) error {
-				config, err := initClient()
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				defer stop()
+
+				config := parseConfig()
+				whatsAppClient := whatsapp.NewClient(profilePath("pegass.db"))
+				var botService = BotService{
+					chatClient: &whatsAppClient,
+					adminGroup: config.WhatsAppAdminGroup,
+				}
+				metricsAddress := c.String("metrics-listen")
+				if metricsAddress != "" {
+					botService.metrics = metrics.New()
+					whatsAppClient.SetMetrics(botService.metrics)
+				}
+				err := loadClient(config, botService.metrics, pegass.WithAuthenticationWarningHandler(botService.HandleAuthenticationWarning))
 				if err != nil {
 					return err
 				}
+				botService.pegassClient = pegassClient
 
-				whatsAppClient := whatsapp.NewClient()
-				var botService = BotService{
-					pegassClient: &pegassClient,
-					chatClient:   &whatsAppClient,
+				err = pegassClient.AuthenticateIfNecessaryContext(ctx)
+				if err != nil {
+					if redcross.IsAccountUnusable(err) {
+						botService.AlertAdmins(fmt.Sprintf("Le bot ne peut pas démarrer, le compte de service ne peut plus se connecter à Pegass : %s", err.Error()))
+					}
+					return err
+				}
+				if metricsAddress != "" {
+					err = serveMetrics(ctx, metricsAddress, botService.metrics)
+					if err != nil {
+						return err
+					}
+					go botService.RefreshIncompleteSeances(ctx, METRICS_REFRESH_INTERVAL)
 				}
+
+				commandTimeout := c.Duration("command-timeout")
 				whatsAppClient.SetMessageCallback(func(senderName string, senderId types.JID, chatId types.JID, content string, timestamp time.Time) {
 					if !isGroupOwnedByCRF(config.WhatsAppBotGroups, chatId.String()) {
 						// Security: only whitelisted groups are able to use bot features
@@ -319,24 +684,41 @@ func main() {
 					}
 
 					var recipient = chatId
-					lowerMessage := strings.ToLower(content)
+					kinds := pegassClient.ActivityKinds()
+					command := botCommand(content, kinds)
+					if command == "" {
+						return
+					}
 
-					err = pegassClient.AuthenticateIfNecessary()
+					botService.pegassMutex.Lock()
+					defer botService.pegassMutex.Unlock()
+					commandCtx, cancel := context.WithTimeout(logging.WithCorrelationID(ctx, logging.NewCorrelationID()), commandTimeout)
+					defer cancel()
+					logging.FromContext(commandCtx).WithField("command", command).Info("handling bot command")
+
+					err := pegassClient.AuthenticateIfNecessaryContext(commandCtx)
 					if err != nil {
-						log.Errorf("failed to authenticate to pegass: '%s'", err.Error())
+						botService.HandleAuthenticationFailure(commandCtx, recipient, err)
+						botService.countCommand(command, err)
 						return
 					}
 
-					if strings.HasPrefix(lowerMessage, "!psr") {
-						botService.SendActivitySummary(recipient, SAMU, 3)
-					} else if strings.HasPrefix(lowerMessage, "!bspp") {
-						botService.SendActivitySummary(recipient, BSPP, 3)
-					} else if strings.HasPrefix(lowerMessage, "!today") {
-						botService.SendActivitySummary(recipient, SAMU, 1)
-						botService.SendActivitySummary(recipient, BSPP, 1)
+					if command == pegass.ALL_KINDS_TRIGGER {
+						var errs []error
+						for _, kind := range kinds {
+							errs = append(errs, botService.SendActivitySummary(commandCtx, recipient, kind, 1))
+						}
+						err = errors.Join(errs...)
+					} else {
+						for _, kind := range kinds {
+							if strings.EqualFold(kind.Trigger, command) {
+								err = botService.SendActivitySummary(commandCtx, recipient, kind, 3)
+							}
+						}
 					}
+					botService.countCommand(command, err)
 				})
-				err = whatsAppClient.StartBot()
+				err = whatsAppClient.StartBotContext(ctx)
 				if err != nil {
 					return err
 				}
@@ -346,14 +728,74 @@ func main() {
 	}
 	err := app.Run(os.Args)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal(describeError(err))
 	}
 }
 
-func parseConfig() Config {
-	configFile, err := os.Open("config.json")
+// botCommand returns the name of the command starting the message, without its '!' prefix, or an empty
+// string. Commands are the triggers of kinds, and ALL_KINDS_TRIGGER.
+func botCommand(message string, kinds []pegass.ActivityKind) string {
+	lowerMessage := strings.ToLower(message)
+	commands := []string{pegass.ALL_KINDS_TRIGGER}
+	for _, kind := range kinds {
+		if kind.Trigger != "" {
+			commands = append(commands, strings.ToLower(kind.Trigger))
+		}
+	}
+	for _, command := range commands {
+		if strings.HasPrefix(lowerMessage, "!"+command) {
+			return command
+		}
+	}
+	return ""
+}
+
+// serveMetrics exposes m, with Go runtime and process metrics, on /metrics until ctx is done.
+func serveMetrics(ctx context.Context, address string, m *metrics.Metrics) error {
+	registry := metrics.NewRegistry()
+	err := m.Register(registry)
 	if err != nil {
-		log.Fatal("Failed to open application configuration file 'config.json'", err)
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(registry))
+	server := &http.Server{Addr: address, Handler: mux}
+
+	go func() {
+		log.Infof("Serving Prometheus metrics on http://%s/metrics", address)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("failed to serve metrics: %s", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	return nil
+}
+
+// describeError adds a hint to the errors users can act upon.
+func describeError(err error) string {
+	var apiError *redcross.PegassAPIError
+	switch {
+	case errors.Is(err, redcross.ErrPegassAuthentication):
+		return fmt.Sprintf("Pegass rejected the session, please log in again with 'pegass-cli login' (%s)", err)
+	case errors.As(err, &apiError) && apiError.StatusCode >= http.StatusInternalServerError:
+		return fmt.Sprintf("Pegass seems to be unavailable, please try again later (%s)", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("Pegass did not answer in time, please try again later or raise --timeout (%s)", err)
+	}
+	return err.Error()
+}
+
+func parseConfig() Config {
+	configFile, err := os.Open(profilePath("config.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		// Secrets live in the vault, so the configuration file is optional
+		return Config{}
+	} else if err != nil {
+		log.Fatalf("Failed to open application configuration file '%s': %s", profilePath("config.json"), err)
 	}
 	defer configFile.Close()
 
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const namespace = "pegass"
+
+// Metrics holds the collectors of the CLI and the bot. They are created by New, and only exposed once registered
+// with Register, so that importing the packages reporting them has no side effect. Packages reporting metrics
+// accept a nil *Metrics, and then report nothing.
+type Metrics struct {
+	HTTPRequests           *prometheus.CounterVec
+	HTTPRequestDuration    *prometheus.HistogramVec
+	AuthenticationAttempts *prometheus.CounterVec
+	AuthenticationFailures *prometheus.CounterVec
+	WhatsAppConnected      prometheus.Gauge
+	WhatsAppReconnects     prometheus.Counter
+	BotCommands            *prometheus.CounterVec
+	IncompleteSeances      *prometheus.GaugeVec
+}
+
+func New() *Metrics {
+	return &Metrics{
+		HTTPRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests sent to Okta and Pegass, by host, method, endpoint and status code.",
+		}, []string{"host", "method", "endpoint", "code"}),
+
+		HTTPRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the HTTP requests sent to Okta and Pegass, by host, method and endpoint.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"host", "method", "endpoint"}),
+
+		AuthenticationAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "authentication_attempts_total",
+			Help:      "Logins to Pegass, through the full Okta flow or by reusing the Okta session.",
+		}, []string{"flow"}),
+
+		AuthenticationFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "authentication_failures_total",
+			Help:      "Failed logins to Pegass, by flow and reason.",
+		}, []string{"flow", "reason"}),
+
+		WhatsAppConnected: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "whatsapp_connected",
+			Help:      "Whether the bot is connected to WhatsApp (1) or not (0).",
+		}),
+
+		WhatsAppReconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "whatsapp_reconnects_total",
+			Help:      "Connections to WhatsApp established after a disconnection.",
+		}),
+
+		BotCommands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bot_commands_total",
+			Help:      "Bot commands handled, by command and outcome.",
+		}, []string{"command", "outcome"}),
+
+		IncompleteSeances: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "incomplete_seances",
+			Help:      "Incomplete seances starting within the next 24 hours, by activity kind.",
+		}, []string{"kind"}),
+	}
+}
+
+// Register exposes every collector through the given registerer.
+func (m *Metrics) Register(registerer prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		m.HTTPRequests,
+		m.HTTPRequestDuration,
+		m.AuthenticationAttempts,
+		m.AuthenticationFailures,
+		m.WhatsAppConnected,
+		m.WhatsAppReconnects,
+		m.BotCommands,
+		m.IncompleteSeances,
+	}
+	for _, collector := range collectors {
+		err := registerer.Register(collector)
+		if err != nil {
+			return fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+	return nil
+}
+
+// NewRegistry returns a registry exposing Go runtime and process metrics, to register Metrics with.
+func NewRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return registry
+}
+
+// Outcomes of bot commands.
+const (
+	OUTCOME_SUCCESS = "success"
+	OUTCOME_FAILURE = "failure"
+)
+
+// Handler serves the metrics of the given registry in the Prometheus exposition format.
+func Handler(gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+}
+
+// Transport is a http.RoundTripper recording the count and latency of requests, unless its metrics are nil.
+type Transport struct {
+	next    http.RoundTripper
+	metrics *Metrics
+}
+
+func NewTransport(next http.RoundTripper, metrics *Metrics) *Transport {
+	return &Transport{next: next, metrics: metrics}
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if t.metrics == nil {
+		return t.next.RoundTrip(request)
+	}
+	endpoint := NormalizeEndpoint(request.URL.Path)
+	start := time.Now()
+	response, err := t.next.RoundTrip(request)
+	t.metrics.HTTPRequestDuration.WithLabelValues(request.URL.Host, request.Method, endpoint).Observe(time.Since(start).Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(response.StatusCode)
+	}
+	t.metrics.HTTPRequests.WithLabelValues(request.URL.Host, request.Method, endpoint, code).Inc()
+	return response, err
+}
+
+// NormalizeEndpoint replaces identifiers in a path with "{id}", so that requests for different activities or
+// users share the same labels. Identifiers are numbers, or long segments containing digits such as NIVOLs
+// and Okta factor ids, as opposed to e.g. "v1" or "SAML2".
+func NormalizeEndpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		hasDigit := strings.IndexFunc(segment, unicode.IsDigit) >= 0
+		isNumber := segment != "" && strings.IndexFunc(segment, func(r rune) bool { return !unicode.IsDigit(r) }) < 0
+		if isNumber || (hasDigit && len(segment) >= 8) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,197 @@
+package mockserver
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	sessionCookieName = "_shibsession_mock"
+	sessionToken      = "mock-session-token"
+	samlResponse      = "mock-saml-response"
+)
+
+//go:embed fixtures
+var embeddedFixtures embed.FS
+
+// DefaultFixtures returns the fixtures bundled with the CLI: a fake department 92 with a few local units,
+// users and SAMU/BSPP activities.
+func DefaultFixtures() fs.FS {
+	fixtures, err := fs.Sub(embeddedFixtures, "fixtures")
+	if err != nil {
+		panic(err)
+	}
+	return fixtures
+}
+
+// Server fakes the Okta and Pegass endpoints used by the CLI. Pegass REST responses are read from a
+// fixture tree mirroring the '/crf/rest' paths, e.g. 'activite/<id>.json' or 'seance/<id>/inscription.json'.
+type Server struct {
+	fixtures    fs.FS
+	oktaAppPath string
+	samlACSPath string
+}
+
+func NewServer(fixtures fs.FS, oktaAppPath string, samlACSPath string) *Server {
+	return &Server{
+		fixtures:    fixtures,
+		oktaAppPath: oktaAppPath,
+		samlACSPath: samlACSPath,
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/authn", s.handleAuthn)
+	mux.HandleFunc("POST /api/v1/authn/factors/{factorId}/verify", s.handleFactorVerify)
+	mux.HandleFunc("GET "+s.oktaAppPath, s.handleSamlForm)
+	mux.HandleFunc("POST "+s.samlACSPath, s.handleSamlACS)
+	mux.HandleFunc("/crf/rest/", s.handlePegass)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.WithFields(log.Fields{
+			"method": r.Method,
+			"uri":    r.URL.RequestURI(),
+		}).Info("mock server received request")
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleAuthn(w http.ResponseWriter, r *http.Request) {
+	if s.serveFixture(w, "okta/authn.json") {
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"stateToken": "mock-state-token",
+		"status":     "MFA_REQUIRED",
+		"_embedded": map[string]interface{}{
+			"factors": []map[string]interface{}{
+				{"id": "mock-totp", "factorType": "token:software:totp", "provider": "GOOGLE", "vendorName": "GOOGLE"},
+				{"id": "mock-push", "factorType": "push", "provider": "OKTA", "vendorName": "OKTA"},
+				{"id": "mock-sms", "factorType": "sms", "provider": "OKTA", "vendorName": "OKTA", "profile": map[string]string{"phoneNumber": "+33 6 XX XX XX 00"}},
+			},
+		},
+	})
+}
+
+func (s *Server) handleFactorVerify(w http.ResponseWriter, r *http.Request) {
+	if s.serveFixture(w, "okta/verify.json") {
+		return
+	}
+
+	var payload struct {
+		PassCode string `json:"passCode"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&payload)
+	if r.PathValue("factorId") == "mock-sms" && payload.PassCode == "" {
+		writeJSON(w, map[string]interface{}{
+			"stateToken":   "mock-state-token",
+			"status":       "MFA_CHALLENGE",
+			"factorResult": "CHALLENGE",
+		})
+		return
+	}
+
+	// Any code is accepted, and push notifications are approved right away
+	writeJSON(w, map[string]interface{}{
+		"status":       "SUCCESS",
+		"sessionToken": sessionToken,
+	})
+}
+
+var samlFormTemplate = template.Must(template.New("saml").Parse(`<html><body>
+<form method="POST" action="{{.Action}}">
+<input type="hidden" name="SAMLResponse" value="{{.SAMLResponse}}"/>
+<input type="submit" value="Continue"/>
+</form>
+</body></html>`))
+
+func (s *Server) handleSamlForm(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("sessionToken") == "" {
+		if _, err := r.Cookie("sid"); err != nil {
+			http.Error(w, "no Okta session", http.StatusUnauthorized)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: "sid", Value: "mock-okta-session", Path: "/"})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = samlFormTemplate.Execute(w, map[string]string{
+		"Action":       s.samlACSPath,
+		"SAMLResponse": samlResponse,
+	})
+}
+
+func (s *Server) handleSamlACS(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("SAMLResponse") != samlResponse {
+		http.Error(w, "invalid SAML response", http.StatusForbidden)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookieName, Value: "mock-pegass-session", Path: "/"})
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handlePegass(w http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie(sessionCookieName); err != nil {
+		// Pegass redirects unauthenticated users to the login page
+		http.Redirect(w, r, s.oktaAppPath, http.StatusFound)
+		return
+	}
+
+	resource := strings.TrimPrefix(r.URL.Path, "/crf/rest/")
+	for _, candidate := range fixtureCandidates(resource, r) {
+		if s.serveFixture(w, candidate) {
+			return
+		}
+	}
+
+	log.Warnf("no fixture found for '%s'", r.URL.RequestURI())
+	http.Error(w, fmt.Sprintf("no fixture found for '%s'", resource), http.StatusNotFound)
+}
+
+// fixtureCandidates lists the fixture files that may answer a Pegass request, most specific first.
+func fixtureCandidates(resource string, r *http.Request) []string {
+	resource = strings.Trim(path.Clean("/"+resource), "/")
+
+	// statistiques/benevole/<nivol>/<debut>/<fin>/quantite does not depend on dates in fixtures
+	if segments := strings.Split(resource, "/"); len(segments) == 6 && segments[0] == "statistiques" {
+		resource = path.Join(segments[0], segments[1], segments[2])
+	}
+
+	var candidates []string
+	if page := r.URL.Query().Get("page"); page != "" && page != "0" {
+		candidates = append(candidates, fmt.Sprintf("%s.page-%s.json", resource, page))
+	}
+	candidates = append(candidates, resource+".json")
+	if nivol := r.URL.Query().Get("utilisateur"); nivol != "" {
+		candidates = append(candidates, path.Join(resource, nivol+".json"), path.Join(resource, "default.json"))
+	}
+	if dir := path.Dir(resource); dir != "." {
+		candidates = append(candidates, path.Join(dir, "default.json"))
+	}
+	return candidates
+}
+
+func (s *Server) serveFixture(w http.ResponseWriter, name string) bool {
+	content, err := fs.ReadFile(s.fixtures, name)
+	if err != nil {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(content)
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		log.Errorf("failed to write mock response: %s", err)
+	}
+}
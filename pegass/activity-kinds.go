@@ -0,0 +1,142 @@
+package pegass
+
+import (
+	"fmt"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"slices"
+	"strings"
+)
+
+// ActivityKind is a family of activities summarized together, e.g. the SAMU network or first aid posts.
+type ActivityKind struct {
+	// Name identifies the kind in commands and metrics, e.g. "samu".
+	Name string
+	// Label is how the kind is shown to volunteers, e.g. "SAMU".
+	Label string
+	// ActionIDs are the Pegass actions searched, e.g. 65 for the first aid network.
+	ActionIDs []int
+	// TypeActiviteIDs restrict the kind to some activity types of these actions. Every type is kept when empty.
+	TypeActiviteIDs []int
+	// Trigger is the bot command replying with the summary of the kind, without its '!' prefix, e.g. "psr".
+	Trigger string
+	// Title names the kind in the daily notification, e.g. "du réseau de secours". Defaults to "des postes <Label>".
+	Title string
+}
+
+func (k ActivityKind) String() string {
+	if k.Label == "" {
+		return k.Name
+	}
+	return k.Label
+}
+
+// Headline completes "Etat ... de demain" in the daily notification, e.g. "du réseau de secours".
+func (k ActivityKind) Headline() string {
+	if k.Title == "" {
+		return "des postes " + k.String()
+	}
+	return k.Title
+}
+
+// matches reports whether act is an activity of this kind, carried out by a structure.
+func (k ActivityKind) matches(act redcross.Activity) bool {
+	if act.StructureMenantActivite.ID == 0 || !slices.Contains(k.ActionIDs, act.TypeActivite.Action.ID) {
+		// Skip unaffected activities and activities of other actions
+		return false
+	}
+	return len(k.TypeActiviteIDs) == 0 || slices.Contains(k.TypeActiviteIDs, act.TypeActivite.ID)
+}
+
+const (
+	ACTION_RESEAU_DE_SECOURS_ID = 65
+	ACTIVITY_RESEAU_15_ID       = 10115
+	ACTIVITY_RESEAU_18_ID       = 10116
+	ACTIVITY_REGULATION_ID      = 10114
+)
+
+// ALL_KINDS_TRIGGER is the bot command replying with today's summary of every kind.
+const ALL_KINDS_TRIGGER = "today"
+
+// DEFAULT_ACTIVITY_KINDS are the first aid network activities of the SAMU (including dispatch) and of the
+// BSPP, used unless other kinds are configured with WithActivityKinds.
+var DEFAULT_ACTIVITY_KINDS = []ActivityKind{
+	{
+		Name:            "samu",
+		Label:           "SAMU",
+		ActionIDs:       []int{ACTION_RESEAU_DE_SECOURS_ID},
+		TypeActiviteIDs: []int{ACTIVITY_RESEAU_15_ID, ACTIVITY_REGULATION_ID},
+		Trigger:         "psr",
+		Title:           "du réseau de secours",
+	},
+	{
+		Name:            "bspp",
+		Label:           "BSPP",
+		ActionIDs:       []int{ACTION_RESEAU_DE_SECOURS_ID},
+		TypeActiviteIDs: []int{ACTIVITY_RESEAU_18_ID},
+		Trigger:         "bspp",
+	},
+}
+
+// ValidateActivityKinds checks that kinds have a name, at least one action, and that their names are unique.
+// Bot commands being matched by prefix, no trigger may start with another one, ALL_KINDS_TRIGGER included.
+func ValidateActivityKinds(kinds []ActivityKind) error {
+	names := make(map[string]bool)
+	triggers := map[string]string{ALL_KINDS_TRIGGER: "the summary of every kind"}
+	for i, kind := range kinds {
+		if kind.Name == "" {
+			return fmt.Errorf("activity kind #%d has no name", i+1)
+		}
+		name := strings.ToLower(kind.Name)
+		if names[name] {
+			return fmt.Errorf("activity kind '%s' is defined twice", kind.Name)
+		}
+		names[name] = true
+		if len(kind.ActionIDs) == 0 {
+			return fmt.Errorf("activity kind '%s' has no action id", kind.Name)
+		}
+		if kind.Trigger == "" {
+			continue
+		}
+		trigger := strings.ToLower(kind.Trigger)
+		for other, owner := range triggers {
+			if strings.HasPrefix(trigger, other) || strings.HasPrefix(other, trigger) {
+				return fmt.Errorf("bot trigger '%s' of activity kind '%s' overlaps with trigger '%s' of %s", kind.Trigger, kind.Name, other, owner)
+			}
+		}
+		triggers[trigger] = fmt.Sprintf("activity kind '%s'", kind.Name)
+	}
+	return nil
+}
+
+// ActivityKinds returns the configured kinds, DEFAULT_ACTIVITY_KINDS if none are.
+func (p *PegassClient) ActivityKinds() []ActivityKind {
+	if len(p.activityKinds) == 0 {
+		return DEFAULT_ACTIVITY_KINDS
+	}
+	return p.activityKinds
+}
+
+// ActivityKind returns the configured kind of the given name, e.g. "samu", case being ignored.
+func (p *PegassClient) ActivityKind(name string) (ActivityKind, error) {
+	var names []string
+	for _, kind := range p.ActivityKinds() {
+		if strings.EqualFold(kind.Name, name) {
+			return kind, nil
+		}
+		names = append(names, kind.Name)
+	}
+	return ActivityKind{}, fmt.Errorf("unknown activity kind '%s' (expected one of: %s)", name, strings.Join(names, ", "))
+}
+
+// actionIDs lists the actions searched for the given kinds, once each.
+func actionIDs(kinds []ActivityKind) []int {
+	var ids []int
+	for _, kind := range kinds {
+		for _, id := range kind.ActionIDs {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,54 @@
+package pegass
+
+import (
+	"context"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"github.com/fabien-chebel/pegass-cli/summary"
+	"net/url"
+	"time"
+)
+
+// Client is what tools built on top of Pegass depend on. It is implemented by *PegassClient, and may be
+// mocked in tests.
+type Client interface {
+	AuthenticateContext(ctx context.Context) error
+	AuthenticateIfNecessaryContext(ctx context.Context) error
+
+	// Users
+	GetCurrentUserContext(ctx context.Context) (redcross.GestionDesDroits, error)
+	GetUserDetailsContext(ctx context.Context, nivol string) (redcross.Utilisateur, error)
+	GetMainMoyenComForUserContext(ctx context.Context, nivol string) (string, error)
+	GetDispatchersContext(ctx context.Context) ([]redcross.Utilisateur, error)
+	GetUsersForRoleContext(ctx context.Context, role redcross.Role) ([]redcross.Utilisateur, error)
+	GetUsersForTrainingRoleContext(ctx context.Context, role redcross.Role) ([]redcross.Utilisateur, error)
+	SearchUsersContext(ctx context.Context, query url.Values) (*Paginator[redcross.Utilisateur], error)
+	AdvancedSearchUsersContext(ctx context.Context, search redcross.AdvancedSearch, pageSize int) (*Paginator[redcross.Utilisateur], error)
+
+	// Activities and seances
+	ActivityKinds() []ActivityKind
+	ActivityKind(name string) (ActivityKind, error)
+	SearchSeancesContext(ctx context.Context, query url.Values) (*Paginator[redcross.Seance], error)
+	FindActivitiesOnDayContext(ctx context.Context, day string, kind ActivityKind, shouldCensorData bool) (string, error)
+	SummarizeDayContext(ctx context.Context, day string, kind ActivityKind, shouldCensorData bool) (summary.DaySummary, error)
+	FindSeancesContext(ctx context.Context, filter SeanceFilter) ([]SeanceRow, error)
+	CountIncompleteSeancesContext(ctx context.Context, period time.Duration) (map[string]int, error)
+
+	// Trainings and roles
+	GetTrainingsForUserContext(ctx context.Context, nivol string) ([]redcross.UserTraining, error)
+	IsFormerFirstResponderContext(ctx context.Context, nivol string) (bool, error)
+	FindRoleByNameContext(ctx context.Context, roleName string) (redcross.Role, error)
+	RoleCatalogContext(ctx context.Context) (*RoleCatalog, error)
+
+	// Structures
+	GetStructuresForZoneContext(ctx context.Context, zone Zone) (map[int]string, error)
+	GetStructureContext(ctx context.Context, id int) (redcross.Structure, error)
+	GetStructureTreeContext(ctx context.Context, zone Zone) ([]*StructureNode, error)
+	GetStructuresForDepartmentContext(ctx context.Context, department string) (map[int]string, error)
+	GetAllStructuresForDepartmentContext(ctx context.Context, department string) ([]int, error)
+
+	// Stats
+	GetStatsForUserContext(ctx context.Context, nivol string) (redcross.StatsBenevole, error)
+	GetActivityStatsContext(ctx context.Context) (map[string]redcross.RegulationStats, error)
+}
+
+var _ Client = (*PegassClient)(nil)
@@ -0,0 +1,133 @@
+package pegass
+
+import (
+	"context"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/lint"
+	"github.com/fabien-chebel/pegass-cli/logging"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"github.com/fabien-chebel/pegass-cli/summary"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"time"
+)
+
+// SummarizeDayContext describes the activities of a kind on a given day, grouped by activity. Crews are only
+// checked for Red Cross activities, and not at all when shouldCensorData is set.
+func (p *PegassClient) SummarizeDayContext(ctx context.Context, day string, kind ActivityKind, shouldCensorData bool) (summary.DaySummary, error) {
+	var daySummary = summary.DaySummary{Day: day, Kind: kind.String()}
+
+	activities, err := p.findActivitiesOnDay(ctx, day, kind.ActionIDs)
+	if err != nil {
+		return daySummary, err
+	}
+
+	sort.Sort(redcross.ByActivity{Activities: activities, Order: p.activityOrder()})
+	structures, err := p.GetStructuresForZoneContext(ctx, p.Zone())
+	if err != nil {
+		return daySummary, fmt.Errorf("failed to summarize activities: %w", err)
+	}
+
+	for _, act := range activities {
+		if !kind.matches(act) {
+			continue
+		}
+
+		association, isExternal := p.externalAssociation(act.Responsable.ID)
+		var isCRFActivity = !isExternal
+
+		if len(daySummary.Sections) == 0 || daySummary.Sections[len(daySummary.Sections)-1].Activity != act.Libelle {
+			var section = summary.ActivitySection{Activity: act.Libelle, External: !isCRFActivity}
+			if isCRFActivity && act.StructureMenantActivite.ID != 0 && act.Libelle != summary.REGULATION_ACTIVITY {
+				section.Structure = structures[act.StructureMenantActivite.ID]
+				if section.Structure == "" {
+					section.Structure = shortStructureName(act.StructureMenantActivite.Libelle)
+				}
+			} else if !isCRFActivity {
+				section.Structure = association
+			}
+			daySummary.Sections = append(daySummary.Sections, section)
+		}
+		section := &daySummary.Sections[len(daySummary.Sections)-1]
+
+		seance := act.SeanceList[0]
+		var status = summary.SeanceStatus{
+			ID:     seance.ID,
+			Start:  seance.Debut.Local(),
+			End:    seance.Fin.Local(),
+			Status: act.Statut,
+		}
+		if isCRFActivity && !shouldCensorData {
+			status.Crew, status.Findings, err = p.describeCrew(ctx, act)
+			if err != nil {
+				return daySummary, fmt.Errorf("failed to summarize activities: %w", err)
+			}
+		}
+		section.Seances = append(section.Seances, status)
+	}
+
+	return daySummary, nil
+}
+
+// describeCrew counts the volunteers registered to the first seance of an activity, and lints the crew
+// against the lint rules.
+func (p *PegassClient) describeCrew(ctx context.Context, activity redcross.Activity) (*summary.Crew, []summary.Finding, error) {
+	inscriptions, err := p.getInscriptions(ctx, activity.SeanceList[0].ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	details, err := mapConcurrently(ctx, p.concurrency, inscriptions, p.fetchInscriptionDetails)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var crew = summary.Crew{Registered: len(inscriptions)}
+	var volunteers []lint.Volunteer
+	rules := p.crewRules()
+	for i, inscription := range inscriptions {
+		userDetails, phoneNumber := details[i].user, details[i].phoneNumber
+		volunteers = append(volunteers, lint.Volunteer{
+			Role:                 inscription.Role,
+			Minor:                userDetails.Mineur,
+			FormerFirstResponder: details[i].isFormerFirstResponder,
+		})
+
+		if rules.Includes(lint.ROLE_SET_CHIEFS, inscription.Role) {
+			if phoneNumber == "" {
+				phoneNumber = "(Inconnu)"
+			}
+			crew.Chief = &summary.Contact{FirstName: userDetails.Prenom, LastName: userDetails.Nom, Phone: phoneNumber}
+		} else if rules.Includes(lint.ROLE_SET_DISPATCHERS, inscription.Role) {
+			assoc, ok := p.externalAssociation(inscription.Utilisateur.ID)
+			if ok {
+				crew.DispatcherAssociation = assoc
+			} else {
+				crew.DispatcherAssociation = "CRF"
+			}
+
+			if rules.Includes(lint.ROLE_SET_DISPATCHER_EVALUATORS, inscription.Role) {
+				crew.DispatcherEvaluation = true
+			}
+		} else if !rules.Covers(inscription.Role) {
+			logging.FromContext(ctx).WithFields(log.Fields{
+				"libelle":    activity.Libelle,
+				"activityId": activity.ID,
+				"role":       inscription.Role,
+				"nivol":      inscription.Utilisateur.ID,
+			}).Warnf("came accross unexpected role %s for activity '%s' and start date '%s'", p.describeRole(ctx, inscription.Role), activity.Libelle, time.Time(activity.SeanceList[0].Debut))
+		}
+	}
+	crew.Dispatchers = rules.Count(lint.ROLE_SET_DISPATCHERS, volunteers)
+	crew.RadioOperators = rules.Count(lint.ROLE_SET_RADIO_OPERATORS, volunteers)
+	crew.Trainees = rules.Count(lint.ROLE_SET_TRAINEES, volunteers)
+
+	if crew.Registered == 0 {
+		return &crew, nil, nil
+	}
+	findings, err := rules.Check(activity.TypeActivite.ID, volunteers)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &crew, findings, nil
+}
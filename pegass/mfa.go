@@ -0,0 +1,271 @@
+package pegass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/logging"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"github.com/pquerna/otp/totp"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	MFA_TOTP        = "totp"
+	MFA_TOTP_PROMPT = "totp-prompt"
+	MFA_PUSH        = "push"
+	MFA_SMS         = "sms"
+)
+
+// MFA_FACTORS lists the supported factors, in the order they are tried when no preference is set.
+var MFA_FACTORS = []string{MFA_TOTP, MFA_PUSH, MFA_TOTP_PROMPT, MFA_SMS}
+
+const (
+	pushPollInterval = 3 * time.Second
+	pushTimeout      = 2 * time.Minute
+)
+
+// PromptFunc asks the user for a value, such as a one-time code. It returns an error when no user is
+// available to answer, e.g. when running the bot.
+type PromptFunc func(label string) (string, error)
+
+// MFAVerifier answers an Okta MFA challenge with one kind of enrolled factor.
+type MFAVerifier interface {
+	Name() string
+	Accepts(factor redcross.Factors) bool
+	Verify(ctx context.Context, p *PegassClient, factor redcross.Factors, stateToken string) (string, error)
+}
+
+func newMFAVerifier(name string, p *PegassClient) (MFAVerifier, error) {
+	switch name {
+	case MFA_TOTP:
+		return totpSeedVerifier{secret: p.totpSecretKey}, nil
+	case MFA_TOTP_PROMPT:
+		return totpPromptVerifier{prompt: p.prompt}, nil
+	case MFA_PUSH:
+		return pushVerifier{}, nil
+	case MFA_SMS:
+		return smsVerifier{prompt: p.prompt}, nil
+	default:
+		return nil, fmt.Errorf("unknown MFA factor '%s' (expected one of: %s)", name, strings.Join(MFA_FACTORS, ", "))
+	}
+}
+
+// verifyMFA goes through the enrolled factors, starting with the preferred one, until one of them
+// succeeds. It returns the resulting Okta session token.
+func (p *PegassClient) verifyMFA(ctx context.Context, factors []redcross.Factors, stateToken string) (string, error) {
+	var names []string
+	if p.preferredMFAFactor != "" {
+		names = append(names, p.preferredMFAFactor)
+	}
+	for _, name := range MFA_FACTORS {
+		if name != p.preferredMFAFactor {
+			names = append(names, name)
+		}
+	}
+
+	var errs []error
+	for _, name := range names {
+		verifier, err := newMFAVerifier(name, p)
+		if err != nil {
+			return "", err
+		}
+		for _, factor := range factors {
+			if !verifier.Accepts(factor) {
+				continue
+			}
+			logging.FromContext(ctx).WithFields(log.Fields{
+				"factorType": factor.FactorType,
+				"factorId":   factor.ID,
+				"verifier":   verifier.Name(),
+			}).Debug("trying multi-factor verification")
+
+			sessionToken, err := verifier.Verify(ctx, p, factor, stateToken)
+			if err == nil {
+				return sessionToken, nil
+			}
+			logging.FromContext(ctx).Warnf("MFA verification with factor '%s' failed: %s", verifier.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", verifier.Name(), err))
+		}
+	}
+
+	if len(errs) == 0 {
+		return "", errors.New("no supported MFA factor is enrolled on this account")
+	}
+	return "", fmt.Errorf("all MFA factors failed: %w", errors.Join(errs...))
+}
+
+// verifyFactor sends a verification request for the given factor. Without a pass code, Okta issues a
+// challenge instead (sends a SMS, a push notification...).
+func (p *PegassClient) verifyFactor(ctx context.Context, factorId string, passCode string, stateToken string) (redcross.MFAAuthResponse, error) {
+	return p.postFactorVerification(ctx,
+		p.oktaURL(fmt.Sprintf("/api/v1/authn/factors/%s/verify?rememberDevice=false", factorId)),
+		passCode,
+		stateToken,
+	)
+}
+
+func (p *PegassClient) postFactorVerification(ctx context.Context, uri string, passCode string, stateToken string) (redcross.MFAAuthResponse, error) {
+	var mfaAuthResponse = redcross.MFAAuthResponse{}
+
+	mfaRequest := redcross.MFAAuthRequest{
+		PassCode:   passCode,
+		StateToken: stateToken,
+	}
+	err := p.postToOkta(ctx, uri, mfaRequest, &mfaAuthResponse)
+	if err != nil {
+		return mfaAuthResponse, fmt.Errorf("MFA verification request failed: %w", err)
+	}
+	logging.FromContext(ctx).WithFields(log.Fields{
+		"status":       mfaAuthResponse.Status,
+		"factorResult": mfaAuthResponse.FactorResult,
+	}).Debug("MFA verification request returned")
+
+	return mfaAuthResponse, nil
+}
+
+func sessionTokenFrom(response redcross.MFAAuthResponse) (string, error) {
+	if response.Status != "SUCCESS" || response.SessionToken == "" {
+		return "", fmt.Errorf("MFA verification was not successful (status '%s', factor result '%s')", response.Status, response.FactorResult)
+	}
+	return response.SessionToken, nil
+}
+
+// totpSeedVerifier generates TOTP codes from the seed stored in the vault.
+type totpSeedVerifier struct {
+	secret string
+}
+
+func (v totpSeedVerifier) Name() string {
+	return MFA_TOTP
+}
+
+func (v totpSeedVerifier) Accepts(factor redcross.Factors) bool {
+	return v.secret != "" && factor.FactorType == "token:software:totp"
+}
+
+func (v totpSeedVerifier) Verify(ctx context.Context, p *PegassClient, factor redcross.Factors, stateToken string) (string, error) {
+	code, err := totp.GenerateCode(v.secret, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to generate TOTP code: %w", err)
+	}
+	logging.FromContext(ctx).Debug("generated 2FA totp code")
+
+	response, err := p.verifyFactor(ctx, factor.ID, code, stateToken)
+	if err != nil {
+		return "", err
+	}
+	return sessionTokenFrom(response)
+}
+
+// totpPromptVerifier asks the user for the code displayed by their authenticator app.
+type totpPromptVerifier struct {
+	prompt PromptFunc
+}
+
+func (v totpPromptVerifier) Name() string {
+	return MFA_TOTP_PROMPT
+}
+
+func (v totpPromptVerifier) Accepts(factor redcross.Factors) bool {
+	return v.prompt != nil && factor.FactorType == "token:software:totp"
+}
+
+func (v totpPromptVerifier) Verify(ctx context.Context, p *PegassClient, factor redcross.Factors, stateToken string) (string, error) {
+	code, err := v.prompt(fmt.Sprintf("Code from your authenticator app (%s)", factor.Provider))
+	if err != nil {
+		return "", err
+	}
+
+	response, err := p.verifyFactor(ctx, factor.ID, code, stateToken)
+	if err != nil {
+		return "", err
+	}
+	return sessionTokenFrom(response)
+}
+
+// pushVerifier sends an Okta Verify push notification and polls until it is approved.
+type pushVerifier struct{}
+
+func (v pushVerifier) Name() string {
+	return MFA_PUSH
+}
+
+func (v pushVerifier) Accepts(factor redcross.Factors) bool {
+	return factor.FactorType == "push"
+}
+
+func (v pushVerifier) Verify(ctx context.Context, p *PegassClient, factor redcross.Factors, stateToken string) (string, error) {
+	response, err := p.verifyFactor(ctx, factor.ID, "", stateToken)
+	if err != nil {
+		return "", err
+	}
+	logging.FromContext(ctx).Info("Okta Verify push notification sent, waiting for approval")
+
+	deadline := time.Now().Add(pushTimeout)
+	for response.Status != "SUCCESS" {
+		switch response.FactorResult {
+		case "WAITING":
+		case "REJECTED":
+			return "", errors.New("push notification was rejected")
+		case "TIMEOUT":
+			return "", errors.New("push notification expired")
+		default:
+			return "", fmt.Errorf("unexpected push verification result '%s' (status '%s')", response.FactorResult, response.Status)
+		}
+		if response.Links.Next == nil || response.Links.Next.Href == "" {
+			return "", errors.New("okta did not provide any link to poll push verification")
+		}
+		if time.Now().After(deadline) {
+			return "", errors.New("timed out waiting for push notification approval")
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(pushPollInterval):
+		}
+		response, err = p.postFactorVerification(ctx, response.Links.Next.Href, "", stateToken)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return sessionTokenFrom(response)
+}
+
+// smsVerifier has Okta send a code by SMS, then asks the user for it.
+type smsVerifier struct {
+	prompt PromptFunc
+}
+
+func (v smsVerifier) Name() string {
+	return MFA_SMS
+}
+
+func (v smsVerifier) Accepts(factor redcross.Factors) bool {
+	return v.prompt != nil && factor.FactorType == "sms"
+}
+
+func (v smsVerifier) Verify(ctx context.Context, p *PegassClient, factor redcross.Factors, stateToken string) (string, error) {
+	response, err := p.verifyFactor(ctx, factor.ID, "", stateToken)
+	if err != nil {
+		return "", err
+	}
+	if response.Status != "MFA_CHALLENGE" {
+		return "", fmt.Errorf("expected Okta to send a SMS challenge but instead got status '%s'", response.Status)
+	}
+
+	code, err := v.prompt(fmt.Sprintf("Code received by SMS on %s", factor.Profile.PhoneNumber))
+	if err != nil {
+		return "", err
+	}
+
+	response, err = p.verifyFactor(ctx, factor.ID, code, stateToken)
+	if err != nil {
+		return "", err
+	}
+	return sessionTokenFrom(response)
+}
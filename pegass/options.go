@@ -0,0 +1,168 @@
+package pegass
+
+import (
+	"github.com/fabien-chebel/pegass-cli/cache"
+	"github.com/fabien-chebel/pegass-cli/lint"
+	"github.com/fabien-chebel/pegass-cli/metrics"
+	"github.com/fabien-chebel/pegass-cli/vault"
+	"net/http"
+	"time"
+)
+
+// Endpoints locate the Okta and Pegass instances. Empty values fall back to the production endpoints.
+type Endpoints struct {
+	OktaBaseURL   string
+	PegassBaseURL string
+	OktaAppPath   string
+	SAMLACSPath   string
+}
+
+// SessionStore persists the Pegass session between runs, so that the client does not need to log in every
+// time. *vault.Vault is a SessionStore.
+type SessionStore interface {
+	Session() *vault.Session
+	SetSession(session *vault.Session) error
+}
+
+// Option configures a PegassClient created with New.
+type Option func(p *PegassClient)
+
+// New creates a client. It does not authenticate until a method needing a session is called, or
+// AuthenticateIfNecessary is.
+func New(options ...Option) *PegassClient {
+	p := &PegassClient{}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// WithCredentials sets the Okta username and password, and the seed used to generate TOTP codes, if any.
+func WithCredentials(username string, password string, totpSecretKey string) Option {
+	return func(p *PegassClient) {
+		p.username = username
+		p.password = password
+		p.totpSecretKey = totpSecretKey
+	}
+}
+
+// WithEndpoints targets other Okta and Pegass instances, e.g. a staging environment or a fake server.
+func WithEndpoints(endpoints Endpoints) Option {
+	return func(p *PegassClient) {
+		p.endpoints = endpoints
+	}
+}
+
+// WithHTTPClient uses the transport and timeout of the given client. Cookies are always managed by the
+// PegassClient, so the Jar of the given client is ignored.
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *PegassClient) {
+		p.transport = client.Transport
+		p.timeout = client.Timeout
+	}
+}
+
+// WithTransport sets the transport performing the HTTP requests. http.DefaultTransport is used otherwise.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(p *PegassClient) {
+		p.transport = transport
+	}
+}
+
+// WithTimeout bounds every HTTP request, DEFAULT_HTTP_TIMEOUT being used otherwise. When the transport retries,
+// the timeout covers every attempt and must leave room for them, e.g. transport.Options.Budget().
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *PegassClient) {
+		p.timeout = timeout
+	}
+}
+
+// WithSessionStore restores and saves the Pegass session from the given store.
+func WithSessionStore(store SessionStore) Option {
+	return func(p *PegassClient) {
+		p.sessionStore = store
+	}
+}
+
+// WithCache keeps Pegass responses in the given cache.
+func WithCache(c *cache.Cache) Option {
+	return func(p *PegassClient) {
+		p.cache = c
+	}
+}
+
+// WithConcurrency sets the number of lookups run in parallel when summarizing activities, DEFAULT_CONCURRENCY
+// being used otherwise.
+func WithConcurrency(concurrency int) Option {
+	return func(p *PegassClient) {
+		p.concurrency = concurrency
+	}
+}
+
+// WithMFAFactor sets the name of the MFA factor to try first, see MFA_FACTORS.
+func WithMFAFactor(name string) Option {
+	return func(p *PegassClient) {
+		p.preferredMFAFactor = name
+	}
+}
+
+// WithPrompt enables interactive MFA factors, asking codes with the given function.
+func WithPrompt(prompt PromptFunc) Option {
+	return func(p *PegassClient) {
+		p.prompt = prompt
+	}
+}
+
+// WithAuthenticationWarningHandler is called with non-blocking issues met while logging in, such as a
+// *redcross.PasswordWarning.
+func WithAuthenticationWarningHandler(handler func(warning error)) Option {
+	return func(p *PegassClient) {
+		p.onAuthenticationWarning = handler
+	}
+}
+
+// WithZone restricts searches to the given department, region or structures, DEFAULT_ZONE being used otherwise
+// (DEFAULT_STATS_ZONE for regulation statistics).
+func WithZone(zone Zone) Option {
+	return func(p *PegassClient) {
+		p.searchZone = zone
+	}
+}
+
+// WithActivityKinds replaces DEFAULT_ACTIVITY_KINDS, e.g. to summarize first aid posts or outreach rounds too.
+// Kinds should be checked with ValidateActivityKinds.
+func WithActivityKinds(kinds []ActivityKind) Option {
+	return func(p *PegassClient) {
+		p.activityKinds = kinds
+	}
+}
+
+// WithExternalAssociations replaces DEFAULT_EXTERNAL_ASSOCIATIONS: partner associations, by the NIVOL of the
+// volunteer responsible for their activities.
+func WithExternalAssociations(associations map[string]string) Option {
+	return func(p *PegassClient) {
+		p.externalAssociations = associations
+	}
+}
+
+// WithActivityOrder replaces DEFAULT_ACTIVITY_ORDER, e.g. when a new ambulance call sign appears.
+func WithActivityOrder(names []string) Option {
+	return func(p *PegassClient) {
+		p.activityOrderNames = names
+	}
+}
+
+// WithLintRules replaces lint.DEFAULT_RULES, checking the crews of summarized activities. Rules should be
+// validated first.
+func WithLintRules(rules lint.RuleSet) Option {
+	return func(p *PegassClient) {
+		p.lintRules = &rules
+	}
+}
+
+// WithMetrics reports logins to Pegass. No metrics are reported otherwise.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(p *PegassClient) {
+		p.metrics = m
+	}
+}
@@ -0,0 +1,227 @@
+package pegass
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	log "github.com/sirupsen/logrus"
+	"iter"
+	"net/url"
+	"strconv"
+)
+
+// Page is one page of results of a paginated Pegass search.
+type Page[T any] struct {
+	Items         []T
+	Number        int
+	TotalPages    int
+	TotalElements int
+	Last          bool
+}
+
+func (p Page[T]) isLast() bool {
+	return p.Last || len(p.Items) == 0 || p.Number >= p.TotalPages-1
+}
+
+// PageFetcher fetches the page of the given number, starting from 0.
+type PageFetcher[T any] func(page int) (Page[T], error)
+
+// Paginator streams the results of a paginated Pegass search, fetching pages lazily as items are consumed.
+type Paginator[T any] struct {
+	fetch     PageFetcher[T]
+	firstPage *Page[T]
+}
+
+// NewPaginator creates a paginator fetching pages with the given function, e.g. to mock a Client.
+func NewPaginator[T any](fetch PageFetcher[T]) *Paginator[T] {
+	return &Paginator[T]{fetch: fetch}
+}
+
+func (p *Paginator[T]) first() (Page[T], error) {
+	if p.firstPage == nil {
+		page, err := p.fetch(0)
+		if err != nil {
+			return page, err
+		}
+		p.firstPage = &page
+	}
+	return *p.firstPage, nil
+}
+
+// TotalElements returns the total number of results, as reported by Pegass with the first page.
+func (p *Paginator[T]) TotalElements() (int, error) {
+	page, err := p.first()
+	if err != nil {
+		return 0, err
+	}
+	return page.TotalElements, nil
+}
+
+// TotalPages returns the total number of pages, as reported by Pegass with the first page.
+func (p *Paginator[T]) TotalPages() (int, error) {
+	page, err := p.first()
+	if err != nil {
+		return 0, err
+	}
+	return page.TotalPages, nil
+}
+
+// All iterates over every result. Pages are only fetched when needed, so breaking out of the loop stops
+// fetching. A fetch error is yielded once, and ends the iteration.
+func (p *Paginator[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		page, err := p.first()
+		for {
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if page.isLast() {
+				return
+			}
+			log.Debugf("Done parsing results for page %d / %d", page.Number+1, page.TotalPages)
+			page, err = p.fetch(page.Number + 1)
+		}
+	}
+}
+
+// Collect fetches every page and returns all results.
+func (p *Paginator[T]) Collect() ([]T, error) {
+	var items []T
+	for item, err := range p.All() {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func withPage(uri *url.URL, page int) string {
+	paged := *uri
+	query := paged.Query()
+	query.Set("page", strconv.Itoa(page))
+	paged.RawQuery = query.Encode()
+	return paged.String()
+}
+
+func benevolesPage(recherche redcross.RechercheBenevoles) Page[redcross.Utilisateur] {
+	return Page[redcross.Utilisateur]{
+		Items:         recherche.List,
+		Number:        recherche.Page,
+		TotalPages:    recherche.Total,
+		TotalElements: recherche.TotalElements,
+		Last:          recherche.Last,
+	}
+}
+
+// SearchUsersContext paginates over the '/utilisateur' search endpoint with the given query parameters.
+func (p *PegassClient) SearchUsersContext(ctx context.Context, query url.Values) (*Paginator[redcross.Utilisateur], error) {
+	err := p.init()
+	if err != nil {
+		return nil, err
+	}
+	uri, err := url.Parse(p.pegassURL("/crf/rest/utilisateur"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url to pegass: %w", err)
+	}
+	query.Set("pageInfo", "true")
+	uri.RawQuery = query.Encode()
+
+	return NewPaginator(func(page int) (Page[redcross.Utilisateur], error) {
+		var rechercheBenevoles = redcross.RechercheBenevoles{}
+		err := p.getJSON(ctx, withPage(uri, page), &rechercheBenevoles)
+		if err != nil {
+			return Page[redcross.Utilisateur]{}, fmt.Errorf("failed to search users: %w", err)
+		}
+		return benevolesPage(rechercheBenevoles), nil
+	}), nil
+}
+
+// SearchUsers calls SearchUsersContext with a background context.
+func (p *PegassClient) SearchUsers(query url.Values) (*Paginator[redcross.Utilisateur], error) {
+	return p.SearchUsersContext(context.Background(), query)
+}
+
+// AdvancedSearchUsersContext paginates over the '/utilisateur/advancedSearch' endpoint. The search payload is
+// sent again with every page.
+func (p *PegassClient) AdvancedSearchUsersContext(ctx context.Context, search redcross.AdvancedSearch, pageSize int) (*Paginator[redcross.Utilisateur], error) {
+	err := p.init()
+	if err != nil {
+		return nil, err
+	}
+	uri, err := url.Parse(p.pegassURL("/crf/rest/utilisateur/advancedSearch"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url to pegass: %w", err)
+	}
+	query := uri.Query()
+	query.Set("size", strconv.Itoa(pageSize))
+	uri.RawQuery = query.Encode()
+
+	payload, err := json.Marshal(search)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize search parameters: %w", err)
+	}
+
+	return NewPaginator(func(page int) (Page[redcross.Utilisateur], error) {
+		request, err := p.post(ctx, withPage(uri, page), "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return Page[redcross.Utilisateur]{}, fmt.Errorf("failed to execute advanced pegass search: %w", err)
+		}
+		defer request.Body.Close()
+
+		var rechercheBenevoles = redcross.RechercheBenevoles{}
+		err = decodeResponse(request, &rechercheBenevoles)
+		if err != nil {
+			return Page[redcross.Utilisateur]{}, fmt.Errorf("failed to unmarshal search results: %w", err)
+		}
+		return benevolesPage(rechercheBenevoles), nil
+	}), nil
+}
+
+// AdvancedSearchUsers calls AdvancedSearchUsersContext with a background context.
+func (p *PegassClient) AdvancedSearchUsers(search redcross.AdvancedSearch, pageSize int) (*Paginator[redcross.Utilisateur], error) {
+	return p.AdvancedSearchUsersContext(context.Background(), search, pageSize)
+}
+
+// SearchSeancesContext paginates over the '/seance' search endpoint with the given query parameters.
+func (p *PegassClient) SearchSeancesContext(ctx context.Context, query url.Values) (*Paginator[redcross.Seance], error) {
+	err := p.init()
+	if err != nil {
+		return nil, err
+	}
+	uri, err := url.Parse(p.pegassURL("/crf/rest/seance"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pegass API url: %w", err)
+	}
+	query.Set("pageInfo", "true")
+	uri.RawQuery = query.Encode()
+
+	return NewPaginator(func(page int) (Page[redcross.Seance], error) {
+		var seanceList = redcross.SeanceList{}
+		err := p.getJSON(ctx, withPage(uri, page), &seanceList)
+		if err != nil {
+			return Page[redcross.Seance]{}, fmt.Errorf("failed to search seances: %w", err)
+		}
+		return Page[redcross.Seance]{
+			Items:         seanceList.Content,
+			Number:        seanceList.Number,
+			TotalPages:    seanceList.TotalPages,
+			TotalElements: seanceList.TotalElements,
+			Last:          seanceList.Last,
+		}, nil
+	}), nil
+}
+
+// SearchSeances calls SearchSeancesContext with a background context.
+func (p *PegassClient) SearchSeances(query url.Values) (*Paginator[redcross.Seance], error) {
+	return p.SearchSeancesContext(context.Background(), query)
+}
@@ -0,0 +1,106 @@
+package pegass
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// pagesOf serves items in pages of the given size, recording the pages fetched.
+func pagesOf(items []int, size int, fetched *[]int) PageFetcher[int] {
+	totalPages := (len(items) + size - 1) / size
+	return func(page int) (Page[int], error) {
+		*fetched = append(*fetched, page)
+		start := min(page*size, len(items))
+		end := min(start+size, len(items))
+		return Page[int]{
+			Items:         items[start:end],
+			Number:        page,
+			TotalPages:    totalPages,
+			TotalElements: len(items),
+		}, nil
+	}
+}
+
+func TestPaginatorCollect(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []int
+		size    int
+		fetched []int
+	}{
+		{name: "no result", items: nil, size: 2, fetched: []int{0}},
+		{name: "single page", items: []int{1, 2}, size: 2, fetched: []int{0}},
+		{name: "several pages", items: []int{1, 2, 3, 4, 5}, size: 2, fetched: []int{0, 1, 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fetched []int
+			paginator := NewPaginator(pagesOf(test.items, test.size, &fetched))
+
+			total, err := paginator.TotalElements()
+			if err != nil || total != len(test.items) {
+				t.Errorf("expected %d elements, got %d (%v)", len(test.items), total, err)
+			}
+			items, err := paginator.Collect()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(items) != len(test.items) || (len(items) > 0 && !reflect.DeepEqual(items, test.items)) {
+				t.Errorf("expected items %v, got %v", test.items, items)
+			}
+			if !reflect.DeepEqual(fetched, test.fetched) {
+				t.Errorf("expected pages %v to be fetched, got %v", test.fetched, fetched)
+			}
+		})
+	}
+}
+
+func TestPaginatorStopsFetching(t *testing.T) {
+	var fetched []int
+	paginator := NewPaginator(pagesOf([]int{1, 2, 3, 4, 5}, 2, &fetched))
+	for item, err := range paginator.All() {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if item == 2 {
+			break
+		}
+	}
+	if !reflect.DeepEqual(fetched, []int{0}) {
+		t.Errorf("expected only the first page to be fetched, got %v", fetched)
+	}
+}
+
+func TestPaginatorError(t *testing.T) {
+	failure := errors.New("pegass is down")
+	var fetched []int
+	pages := pagesOf([]int{1, 2, 3, 4, 5}, 2, &fetched)
+	paginator := NewPaginator(func(page int) (Page[int], error) {
+		if page == 1 {
+			return Page[int]{}, failure
+		}
+		return pages(page)
+	})
+
+	var items []int
+	var errs []error
+	for item, err := range paginator.All() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		items = append(items, item)
+	}
+	if !reflect.DeepEqual(items, []int{1, 2}) {
+		t.Errorf("expected the items of the first page, got %v", items)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], failure) {
+		t.Errorf("expected the fetch error to be yielded once, got %v", errs)
+	}
+
+	_, err := paginator.Collect()
+	if !errors.Is(err, failure) {
+		t.Errorf("expected Collect to fail with %v, got %v", failure, err)
+	}
+}
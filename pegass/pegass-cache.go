@@ -0,0 +1,48 @@
+package pegass
+
+import (
+	"context"
+	"github.com/fabien-chebel/pegass-cli/cache"
+	"github.com/fabien-chebel/pegass-cli/logging"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// cacheTTL is how long entries of a kind stay fresh, in the client cache or, without one, in memory. It falls
+// back to cache.DefaultTTLs when the kind is not cached on disk.
+func (p *PegassClient) cacheTTL(kind string) time.Duration {
+	if p.cache != nil && p.cache.TTL(kind) > 0 {
+		return p.cache.TTL(kind)
+	}
+	return cache.DefaultTTLs[kind]
+}
+
+// cached returns the entry of the client cache stored under the given kind and key, or calls fetch and
+// stores its result. Keys are namespaced by the Pegass base URL, so that a mock server or another instance never
+// serves its data in place of the real one. Cache failures are only logged, so that the cache never prevents
+// reaching Pegass.
+func cached[T any](ctx context.Context, p *PegassClient, kind string, key string, fetch func() (T, error)) (T, error) {
+	if p.cache == nil {
+		return fetch()
+	}
+
+	key = p.pegassURL("") + " " + key
+	var value T
+	found, err := p.cache.Get(ctx, kind, key, &value)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("failed to read '%s' from cache: %s", kind, err)
+	} else if found {
+		logging.FromContext(ctx).WithFields(log.Fields{"kind": kind, "key": key}).Debug("cache hit")
+		return value, nil
+	}
+
+	value, err = fetch()
+	if err != nil {
+		return value, err
+	}
+	err = p.cache.Put(ctx, kind, key, value)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("failed to write '%s' to cache: %s", kind, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,1094 @@
+package pegass
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/cache"
+	"github.com/fabien-chebel/pegass-cli/lint"
+	"github.com/fabien-chebel/pegass-cli/logging"
+	"github.com/fabien-chebel/pegass-cli/metrics"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"github.com/fabien-chebel/pegass-cli/summary"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_OKTA_BASE_URL   = "https://connect.croix-rouge.fr"
+	DEFAULT_PEGASS_BASE_URL = "https://pegass.croix-rouge.fr"
+	DEFAULT_OKTA_APP_PATH   = "/home/croix-rouge_pegass_1/0oa2s6fw19Pp8eQzd417/aln2s6knvxzI5pG6x417"
+	DEFAULT_SAML_ACS_PATH   = "/Shibboleth.sso/SAML2/POST"
+	DEFAULT_HTTP_TIMEOUT    = 60 * time.Second
+)
+
+// PegassClient talks to Pegass on behalf of a volunteer, logging in through Okta when needed. Create it with New.
+// It is safe for concurrent use: lookups share the session, and logins are serialized.
+type PegassClient struct {
+	// sessionMutex guards cookieJar and httpClient, which are replaced when logging in again. authMutex
+	// serializes logins, so that concurrent callers do not log in twice.
+	sessionMutex       sync.Mutex
+	authMutex          sync.Mutex
+	cookieJar          *sessionJar
+	httpClient         *http.Client
+	username           string
+	password           string
+	totpSecretKey      string
+	sessionStore       SessionStore
+	endpoints          Endpoints
+	transport          http.RoundTripper
+	timeout            time.Duration
+	cache              *cache.Cache
+	concurrency        int
+	preferredMFAFactor string
+	prompt             PromptFunc
+	searchZone         Zone
+	activityKinds      []ActivityKind
+	// externalAssociations and activityOrderNames fall back to the defaults when nil.
+	externalAssociations map[string]string
+	activityOrderNames   []string
+	lintRules            *lint.RuleSet
+	metrics              *metrics.Metrics
+	// roleCatalog is loaded on first use, and kept until it expires, see RoleCatalogContext.
+	roleCatalogMutex    sync.Mutex
+	roleCatalog         *RoleCatalog
+	roleCatalogLoadedAt time.Time
+	// onAuthenticationWarning is called with non-blocking issues met while logging in, such as a
+	// *redcross.PasswordWarning.
+	onAuthenticationWarning func(warning error)
+}
+
+func (p *PegassClient) init() error {
+	p.sessionMutex.Lock()
+	defer p.sessionMutex.Unlock()
+	if p.cookieJar == nil {
+		jar, err := newSessionJar()
+		p.cookieJar = jar
+		if err != nil {
+			return fmt.Errorf("failed to create cookie jar: %w", err)
+		}
+	}
+	if p.httpClient != nil && p.httpClient.Jar == http.CookieJar(p.cookieJar) {
+		// Already initialized: concurrent lookups keep sharing the same client
+		return nil
+	}
+	p.httpClient = &http.Client{
+		Jar:       p.cookieJar,
+		Transport: p.transport,
+		Timeout:   p.requestTimeout(),
+	}
+	return nil
+}
+
+// client returns the HTTP client of the current session, see init.
+func (p *PegassClient) client() *http.Client {
+	p.sessionMutex.Lock()
+	defer p.sessionMutex.Unlock()
+	return p.httpClient
+}
+
+// jar returns the cookie jar of the current session, nil when there is none.
+func (p *PegassClient) jar() *sessionJar {
+	p.sessionMutex.Lock()
+	defer p.sessionMutex.Unlock()
+	return p.cookieJar
+}
+
+// setJar replaces the cookie jar of the session. A nil jar drops the session, a new one being created by init.
+func (p *PegassClient) setJar(jar *sessionJar) {
+	p.sessionMutex.Lock()
+	defer p.sessionMutex.Unlock()
+	p.cookieJar = jar
+}
+
+func (p *PegassClient) requestTimeout() time.Duration {
+	if p.timeout == 0 {
+		return DEFAULT_HTTP_TIMEOUT
+	}
+	return p.timeout
+}
+
+func (p *PegassClient) oktaURL(path string) string {
+	baseURL := p.endpoints.OktaBaseURL
+	if baseURL == "" {
+		baseURL = DEFAULT_OKTA_BASE_URL
+	}
+	return strings.TrimSuffix(baseURL, "/") + path
+}
+
+func (p *PegassClient) pegassURL(path string) string {
+	baseURL := p.endpoints.PegassBaseURL
+	if baseURL == "" {
+		baseURL = DEFAULT_PEGASS_BASE_URL
+	}
+	return strings.TrimSuffix(baseURL, "/") + path
+}
+
+func (p *PegassClient) get(ctx context.Context, uri string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return p.client().Do(request)
+}
+
+func (p *PegassClient) post(ctx context.Context, uri string, contentType string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", contentType)
+	return p.client().Do(request)
+}
+
+func (p *PegassClient) postForm(ctx context.Context, uri string, data url.Values) (*http.Response, error) {
+	return p.post(ctx, uri, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+}
+
+// decodeResponse decodes the JSON body of a Pegass response into target. Error statuses, redirections to the
+// login page and undecodable bodies are returned as *redcross.PegassAPIError.
+func decodeResponse(response *http.Response, target interface{}) error {
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response from Pegass: %w", err)
+	}
+
+	apiError := &redcross.PegassAPIError{
+		Method:      response.Request.Method,
+		Endpoint:    response.Request.URL.Path,
+		StatusCode:  response.StatusCode,
+		ContentType: response.Header.Get("Content-Type"),
+		Body:        snippet(body),
+	}
+	if redirectedFrom := originalRequest(response.Request); redirectedFrom != response.Request {
+		apiError.Method = redirectedFrom.Method
+		apiError.Endpoint = redirectedFrom.URL.Path
+		apiError.RedirectedTo = response.Request.URL.Redacted()
+	}
+	if response.StatusCode >= http.StatusMultipleChoices || apiError.IsAuthenticationFailure() {
+		return apiError
+	}
+
+	err = json.Unmarshal(body, target)
+	if err != nil {
+		apiError.Err = err
+		return apiError
+	}
+	return nil
+}
+
+// originalRequest returns the request sent by the caller, before any redirection.
+func originalRequest(request *http.Request) *http.Request {
+	for request.Response != nil && request.Response.Request != nil {
+		request = request.Response.Request
+	}
+	return request
+}
+
+const maxSnippetLength = 200
+
+func snippet(body []byte) string {
+	text := strings.Join(strings.Fields(string(body)), " ")
+	if len(text) > maxSnippetLength {
+		return strings.ToValidUTF8(text[:maxSnippetLength], "") + "…"
+	}
+	return text
+}
+
+// getJSON fetches a Pegass resource and decodes its JSON response into target.
+func (p *PegassClient) getJSON(ctx context.Context, uri string, target interface{}) error {
+	response, err := p.get(ctx, uri)
+	if err != nil {
+		return fmt.Errorf("failed to send request to pegass: %w", err)
+	}
+	defer response.Body.Close()
+
+	err = decodeResponse(response, target)
+	if err != nil {
+		return fmt.Errorf("invalid response from Pegass: %w", err)
+	}
+	return nil
+}
+
+func (p *PegassClient) kickOffAuthentication(ctx context.Context, username string, password string) (redcross.PasswordAuthResponse, error) {
+	var passwordAuthResponse = redcross.PasswordAuthResponse{}
+
+	passwordAuthPayload := redcross.PasswordAuth{
+		Password: password,
+		Username: username,
+		Options: redcross.PasswordAuthOptions{
+			WarnBeforePasswordExpired: true,
+			MultiOptionalFactorEnroll: true,
+		},
+	}
+	err := p.postToOkta(ctx, p.oktaURL("/api/v1/authn"), passwordAuthPayload, &passwordAuthResponse)
+	if err != nil {
+		return passwordAuthResponse, fmt.Errorf("password authentication failed: %w", err)
+	}
+
+	return passwordAuthResponse, nil
+}
+
+// postToOkta sends a JSON payload to an Okta authentication endpoint and decodes its response. Okta error
+// payloads are returned as *redcross.OktaAPIError.
+func (p *PegassClient) postToOkta(ctx context.Context, uri string, payload interface{}, response interface{}) error {
+	payloadBuffer := new(bytes.Buffer)
+	err := json.NewEncoder(payloadBuffer).Encode(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode Okta request payload: %w", err)
+	}
+
+	request, err := p.post(ctx, uri, "application/json", payloadBuffer)
+	if err != nil {
+		return fmt.Errorf("failed to send request to Okta: %w", err)
+	}
+	defer request.Body.Close()
+	logging.FromContext(ctx).WithFields(log.Fields{
+		"statusCode": request.StatusCode,
+		"uri":        request.Request.URL.Path,
+	}).Debug("call to okta returned")
+
+	if request.StatusCode >= http.StatusBadRequest {
+		var apiError = redcross.OktaAPIError{StatusCode: request.StatusCode}
+		err = json.NewDecoder(request.Body).Decode(&apiError)
+		if err != nil {
+			return fmt.Errorf("okta returned status %d", request.StatusCode)
+		}
+		return &apiError
+	}
+
+	err = json.NewDecoder(request.Body).Decode(response)
+	if err != nil {
+		return fmt.Errorf("failed to decode Okta response as json: %w", err)
+	}
+	return nil
+}
+
+func (p *PegassClient) AuthenticateContext(ctx context.Context) error {
+	p.authMutex.Lock()
+	defer p.authMutex.Unlock()
+	return p.authenticateWithPassword(ctx)
+}
+
+// authenticateWithPassword runs the full Okta login. Callers must hold authMutex.
+func (p *PegassClient) authenticateWithPassword(ctx context.Context) error {
+	err := p.authenticate(ctx)
+	p.recordAuthentication(authFlowPassword, err)
+	return err
+}
+
+func (p *PegassClient) authenticate(ctx context.Context) error {
+	err := p.init()
+	if err != nil {
+		return err
+	}
+
+	passwordAuthResponse, err := p.kickOffAuthentication(ctx, p.username, p.password)
+	if err != nil {
+		return err
+	}
+
+	var sessionToken string
+	for sessionToken == "" {
+		logging.FromContext(ctx).WithFields(log.Fields{
+			"status": passwordAuthResponse.Status,
+		}).Debug("okta authentication transaction moved to a new state")
+
+		switch passwordAuthResponse.Status {
+		case redcross.AuthnStatusSuccess:
+			sessionToken = passwordAuthResponse.SessionToken
+			if sessionToken == "" {
+				return errors.New("okta authentication succeeded without providing any session token")
+			}
+		case redcross.AuthnStatusMFAEnroll:
+			if passwordAuthResponse.Links.Skip == nil {
+				return redcross.ErrMFAEnroll
+			}
+			// Only optional factors remain to be enrolled
+			passwordAuthResponse, err = p.skipAuthenticationStep(ctx, passwordAuthResponse)
+			if err != nil {
+				return err
+			}
+		case redcross.AuthnStatusPasswordWarn:
+			warning := &redcross.PasswordWarning{DaysLeft: passwordAuthResponse.Embedded.Policy.Expiration.PasswordExpireDays}
+			logging.FromContext(ctx).Warnf("Your Okta password expires in %d day(s), please change it on the Red Cross portal", warning.DaysLeft)
+			if p.onAuthenticationWarning != nil {
+				p.onAuthenticationWarning(warning)
+			}
+			passwordAuthResponse, err = p.skipAuthenticationStep(ctx, passwordAuthResponse)
+			if err != nil {
+				return err
+			}
+		case redcross.AuthnStatusMFARequired:
+			sessionToken, err = p.verifyMFA(ctx, passwordAuthResponse.Embedded.Factors, passwordAuthResponse.StateToken)
+			if err != nil {
+				return fmt.Errorf("%w: %w", errMFAChallenge, err)
+			}
+		default:
+			return redcross.NewAuthnError(passwordAuthResponse.Status)
+		}
+	}
+
+	err = p.loginToPegass(ctx, sessionToken)
+	if err != nil {
+		return err
+	}
+
+	err = p.saveSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Println("Authentication succeeded.")
+	return nil
+}
+
+func (p *PegassClient) Authenticate() error {
+	return p.AuthenticateContext(context.Background())
+}
+
+// skipAuthenticationStep moves past an optional step of the Okta transaction, such as a password warning.
+func (p *PegassClient) skipAuthenticationStep(ctx context.Context, current redcross.PasswordAuthResponse) (redcross.PasswordAuthResponse, error) {
+	var next = redcross.PasswordAuthResponse{}
+	if current.Links.Skip == nil || current.Links.Skip.Href == "" {
+		return next, fmt.Errorf("okta did not allow to skip the '%s' step", current.Status)
+	}
+	err := p.postToOkta(ctx, current.Links.Skip.Href, redcross.StateTokenRequest{StateToken: current.StateToken}, &next)
+	if err != nil {
+		return next, fmt.Errorf("failed to skip the '%s' step: %w", current.Status, err)
+	}
+	return next, nil
+}
+
+// loginToPegass exchanges an Okta session for a Pegass session through the SAML flow. When no session
+// token is provided, the Okta session cookies held by the cookie jar are used instead.
+func (p *PegassClient) loginToPegass(ctx context.Context, sessionToken string) error {
+	appPath := p.endpoints.OktaAppPath
+	if appPath == "" {
+		appPath = DEFAULT_OKTA_APP_PATH
+	}
+	appUrl := p.oktaURL(appPath)
+	if sessionToken != "" {
+		appUrl = fmt.Sprintf("%s?sessionToken=%s", appUrl, sessionToken)
+	}
+	request, err := p.get(ctx, appUrl)
+	if err != nil {
+		return fmt.Errorf("failed to authenticate to Pegass: %w", err)
+	}
+	defer request.Body.Close()
+	var samlResponseToken string
+	tokenizer := html.NewTokenizer(request.Body)
+tokenLoop:
+	for {
+		tokenType := tokenizer.Next()
+		switch {
+		case tokenType == html.ErrorToken:
+			break tokenLoop
+		case tokenType == html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data == "input" {
+				for _, a := range token.Attr {
+					if a.Key == "name" && a.Val == "SAMLResponse" {
+						for _, aa := range token.Attr {
+							if aa.Key == "value" {
+								samlResponseToken = aa.Val
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if samlResponseToken == "" {
+		return errors.New("failed to parse SAML Response token")
+	}
+
+	acsPath := p.endpoints.SAMLACSPath
+	if acsPath == "" {
+		acsPath = DEFAULT_SAML_ACS_PATH
+	}
+	authentRequest, err := p.postForm(ctx, p.pegassURL(acsPath), url.Values{
+		"SAMLResponse": {samlResponseToken},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to authenticate on Pegass: %w", err)
+	}
+	defer authentRequest.Body.Close()
+	return nil
+}
+
+// saveSession persists the current Pegass and Okta cookies to the session store, along with the NIVOL of the
+// authenticated user.
+func (p *PegassClient) saveSession(ctx context.Context) error {
+	var nivol string
+	user, err := p.GetCurrentUserContext(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("failed to identify the user owning the new Pegass session: %s", err)
+	} else {
+		nivol = user.Utilisateur.ID
+	}
+
+	session, err := p.exportSession(nivol)
+	if err != nil {
+		return err
+	}
+	if p.sessionStore == nil {
+		return nil
+	}
+	err = p.sessionStore.SetSession(session)
+	if err != nil {
+		return fmt.Errorf("failed to save authentication data: %w", err)
+	}
+	return nil
+}
+
+// AuthenticateIfNecessaryContext makes sure the client holds a valid Pegass session. It first restores the
+// session saved in the session store, then falls back to the Okta session, and finally to a full login.
+func (p *PegassClient) AuthenticateIfNecessaryContext(ctx context.Context) error {
+	p.authMutex.Lock()
+	defer p.authMutex.Unlock()
+
+	if p.jar() == nil {
+		session, err := p.restoreSession()
+		if err != nil {
+			logging.FromContext(ctx).Infof("unable to restore previous session, application will authenticate to pegass: %s", err)
+			p.setJar(nil)
+			return p.authenticateWithPassword(ctx)
+		}
+		logging.FromContext(ctx).WithFields(log.Fields{
+			"nivol":     session.Nivol,
+			"issuedAt":  session.IssuedAt,
+			"expiresAt": session.ExpiresAt,
+		}).Debug("restored previous session")
+	}
+
+	if !p.shouldReAuthenticate(ctx) {
+		logging.FromContext(ctx).Debug("previous authentication ticket is still valid")
+		return nil
+	}
+
+	err := p.init()
+	if err != nil {
+		return err
+	}
+	err = p.loginToPegass(ctx, "")
+	if err == nil && p.shouldReAuthenticate(ctx) {
+		err = redcross.ErrPegassAuthentication
+	}
+	p.recordAuthentication(authFlowOktaSession, err)
+	if err == nil {
+		logging.FromContext(ctx).Info("previous Pegass session expired. re-authenticated using the Okta session")
+		return p.saveSession(ctx)
+	}
+
+	logging.FromContext(ctx).Info("previous authentication ticket expired. application will re-authenticate to pegass")
+	p.setJar(nil)
+
+	return p.authenticateWithPassword(ctx)
+}
+
+// AuthenticateIfNecessary calls AuthenticateIfNecessaryContext with a background context.
+func (p *PegassClient) AuthenticateIfNecessary() error {
+	return p.AuthenticateIfNecessaryContext(context.Background())
+}
+
+func (p *PegassClient) shouldReAuthenticate(ctx context.Context) bool {
+	noRedirectHttpClient := &http.Client{
+		Jar:       p.jar(),
+		Transport: p.transport,
+		Timeout:   p.requestTimeout(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, p.pegassURL("/crf/rest/gestiondesdroits"), nil)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("failed to create reauthenticate check request: '%s'", err.Error())
+		return true
+	}
+	response, err := noRedirectHttpClient.Do(request)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("reauthenticate check request failed: '%s'", err.Error())
+		return true
+	}
+	defer response.Body.Close()
+
+	logging.FromContext(ctx).Debugf("Call to /gestiondesdroits endpoint returned response with code '%d'", response.StatusCode)
+	return response.StatusCode != http.StatusOK
+}
+
+func (p *PegassClient) GetCurrentUserContext(ctx context.Context) (redcross.GestionDesDroits, error) {
+	var user = redcross.GestionDesDroits{}
+	err := p.init()
+	if err != nil {
+		return user, err
+	}
+
+	getRequest, err := p.get(ctx, p.pegassURL("/crf/rest/gestiondesdroits"))
+	if err != nil {
+		return user, fmt.Errorf("failed to create request to Pegass 'gestiondesdroits' endpoint: %w", err)
+	}
+	defer getRequest.Body.Close()
+
+	err = decodeResponse(getRequest, &user)
+	if err != nil {
+		return user, fmt.Errorf("invalid response from Pegass: %w", err)
+	}
+
+	return user, nil
+}
+
+func (p *PegassClient) GetCurrentUser() (redcross.GestionDesDroits, error) {
+	return p.GetCurrentUserContext(context.Background())
+}
+
+func (p *PegassClient) GetStatsForUserContext(ctx context.Context, nivol string) (redcross.StatsBenevole, error) {
+	var stats = redcross.StatsBenevole{}
+	err := p.init()
+	if err != nil {
+		return stats, err
+	}
+
+	startDate := "2021-01-01"
+	endDate := "2021-12-21"
+
+	requestURI := p.pegassURL(fmt.Sprintf("/crf/rest/statistiques/benevole/%s/%s/%s/quantite", nivol, startDate, endDate))
+	getRequest, err := p.get(ctx, requestURI)
+	if err != nil {
+		return stats, fmt.Errorf("failed to create request to pegass 'statistiques benevole' endpoint: %w", err)
+	}
+	defer getRequest.Body.Close()
+
+	stats = redcross.StatsBenevole{}
+	err = decodeResponse(getRequest, &stats)
+	if err != nil {
+		return stats, fmt.Errorf("invalid response from Pegass: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (p *PegassClient) GetStatsForUser(nivol string) (redcross.StatsBenevole, error) {
+	return p.GetStatsForUserContext(context.Background(), nivol)
+}
+
+func (p *PegassClient) GetUserDetailsContext(ctx context.Context, nivol string) (redcross.Utilisateur, error) {
+	return cached(ctx, p, cache.KindUsers, nivol, func() (redcross.Utilisateur, error) {
+		var user = redcross.Utilisateur{}
+		err := p.init()
+		if err != nil {
+			return user, err
+		}
+
+		requestURI := p.pegassURL(fmt.Sprintf("/crf/rest/utilisateur/%s", nivol))
+
+		getRequest, err := p.get(ctx, requestURI)
+		if err != nil {
+			return user, fmt.Errorf("failed to fetch user details: %w", err)
+		}
+		defer getRequest.Body.Close()
+
+		err = decodeResponse(getRequest, &user)
+		if err != nil {
+			return user, fmt.Errorf("invalid response from Pegass: %w", err)
+		}
+
+		return user, nil
+	})
+}
+
+func (p *PegassClient) GetUserDetails(nivol string) (redcross.Utilisateur, error) {
+	return p.GetUserDetailsContext(context.Background(), nivol)
+}
+
+func (p *PegassClient) GetDispatchersContext(ctx context.Context) ([]redcross.Utilisateur, error) {
+	query := url.Values{}
+	query.Add("perPage", "11")
+	query.Add("role", redcross.ROLE_DISPATCHER)
+	query.Add("searchType", "benevoles")
+	query.Add("withMoyensCom", "true")
+	p.Zone().addTo(query)
+
+	paginator, err := p.SearchUsersContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return paginator.Collect()
+}
+
+func (p *PegassClient) GetDispatchers() ([]redcross.Utilisateur, error) {
+	return p.GetDispatchersContext(context.Background())
+}
+
+func (p *PegassClient) GetActivityStatsContext(ctx context.Context) (map[string]redcross.RegulationStats, error) {
+	err := p.init()
+	if err != nil {
+		return nil, err
+	}
+
+	startDate := "2021-01-01"
+	endDate := "2021-12-31"
+
+	query := url.Values{}
+	query.Add("debut", startDate)
+	query.Add("fin", endDate)
+	query.Add("size", "100")
+	query.Add("statut", "COMPLETE")
+	query.Add("typeActivite", "10114") // Regulation
+	p.statsZone().addTo(query)
+
+	paginator, err := p.SearchSeancesContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var seanceIds []string
+	for seance, err := range paginator.All() {
+		if err != nil {
+			return nil, err
+		}
+		seanceIds = append(seanceIds, seance.ID)
+	}
+
+	var statsMap = make(map[string]redcross.RegulationStats)
+
+	for _, id := range seanceIds {
+		logging.FromContext(ctx).Infof("Computing stats for seance '%s'", id)
+
+		inscriptions := redcross.InscriptionList{}
+		err = p.getJSON(ctx, p.pegassURL(fmt.Sprintf("/crf/rest/seance/%s/inscription", id)), &inscriptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch inscriptions of seance '%s': %w", id, err)
+		}
+
+		for _, inscription := range inscriptions {
+			entry, ok := statsMap[inscription.Utilisateur.ID]
+			if !ok {
+				entry = redcross.RegulationStats{
+					OPR:   0,
+					Eval:  0,
+					Regul: 0,
+				}
+			}
+
+			switch inscription.Role {
+			case redcross.ROLE_OPR_TRAINING:
+				entry.OPR++
+			case redcross.ROLE_DISPATCHER:
+				entry.Regul++
+			case redcross.ROLE_PARTICIPANT_OPR:
+				entry.OPR++
+			case redcross.ROLE_DISPATCHER_ASSISTANT:
+				entry.Regul++
+			case redcross.ROLE_DISPATCHER_EVALUATOR:
+				entry.Eval++
+			case "PARTICIPANT":
+				entry.OPR++
+			default:
+				logging.FromContext(ctx).Printf("Unsupported role: %s ; seance id: %s", p.describeRole(ctx, inscription.Role), inscription.Seance.ID)
+			}
+
+			statsMap[inscription.Utilisateur.ID] = entry
+		}
+
+	}
+
+	return statsMap, nil
+}
+
+func (p *PegassClient) GetActivityStats() (map[string]redcross.RegulationStats, error) {
+	return p.GetActivityStatsContext(context.Background())
+}
+
+func (p *PegassClient) GetMainMoyenComForUserContext(ctx context.Context, nivol string) (string, error) {
+	return cached(ctx, p, cache.KindPhones, nivol, func() (string, error) {
+		response, err := p.get(ctx, p.pegassURL(fmt.Sprintf("/crf/rest/moyencomutilisateur?utilisateur=%s", nivol)))
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+
+		var moyenComs []redcross.Coordonnees
+		err = decodeResponse(response, &moyenComs)
+		if err != nil {
+			return "", err
+		}
+
+		for _, com := range moyenComs {
+			if com.MoyenComID == "POR" {
+				return com.Libelle, nil
+			}
+		}
+
+		return "", nil
+	})
+}
+
+func (p *PegassClient) GetMainMoyenComForUser(nivol string) (string, error) {
+	return p.GetMainMoyenComForUserContext(context.Background(), nivol)
+}
+
+func (p *PegassClient) GetUsersForRoleContext(ctx context.Context, role redcross.Role) ([]redcross.Utilisateur, error) {
+	query := url.Values{}
+	query.Add("size", "11")
+	switch role.Type {
+	case redcross.ROLE_TYPE_COMPETENCE:
+		query.Add("role", role.ID)
+	case redcross.ROLE_TYPE_NOMINATION:
+		query.Add("nomination", role.ID)
+	case redcross.ROLE_TYPE_FORMATION:
+		query.Add("formation", role.ID)
+	default:
+		logging.FromContext(ctx).Printf("Unsupported role type '%s'", role.Type)
+		return nil, fmt.Errorf("unsupported role type '%s'", role.Type)
+	}
+	query.Add("searchType", "benevoles")
+	query.Add("withMoyensCom", "true")
+	p.Zone().addTo(query)
+
+	paginator, err := p.SearchUsersContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return paginator.Collect()
+}
+
+func (p *PegassClient) GetUsersForRole(role redcross.Role) ([]redcross.Utilisateur, error) {
+	return p.GetUsersForRoleContext(context.Background(), role)
+}
+
+func (p *PegassClient) GetAllStructuresForDepartmentContext(ctx context.Context, department string) ([]int, error) {
+	structures, err := p.GetStructuresForDepartmentContext(ctx, department)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for key, _ := range structures {
+		ids = append(ids, key)
+	}
+	return ids, nil
+}
+
+func (p *PegassClient) GetAllStructuresForDepartment(department string) ([]int, error) {
+	return p.GetAllStructuresForDepartmentContext(context.Background(), department)
+}
+
+func (p *PegassClient) GetStructuresForDepartmentContext(ctx context.Context, department string) (map[int]string, error) {
+	return p.getZoneGeoStructures(ctx, ZONE_DEPARTMENT, department)
+}
+
+func (p *PegassClient) GetStructuresForDepartment(department string) (map[int]string, error) {
+	return p.GetStructuresForDepartmentContext(context.Background(), department)
+}
+
+func (p *PegassClient) GetUsersForTrainingRoleContext(ctx context.Context, role redcross.Role) ([]redcross.Utilisateur, error) {
+	err := p.init()
+	if err != nil {
+		return nil, err
+	}
+
+	structures, err := p.zoneStructureIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	paginator, err := p.AdvancedSearchUsersContext(ctx, redcross.AdvancedSearch{
+		StructureList:   structures,
+		FormationInList: []string{role.ID},
+		SearchType:      "benevoles",
+		WithMoyensCom:   true,
+	}, 11)
+	if err != nil {
+		return nil, err
+	}
+	return paginator.Collect()
+}
+
+func (p *PegassClient) GetUsersForTrainingRole(role redcross.Role) ([]redcross.Utilisateur, error) {
+	return p.GetUsersForTrainingRoleContext(context.Background(), role)
+}
+
+func (p *PegassClient) FindRoleByNameContext(ctx context.Context, roleName string) (redcross.Role, error) {
+	catalog, err := p.RoleCatalogContext(ctx)
+	if err != nil {
+		return redcross.Role{}, err
+	}
+
+	role, ok := catalog.ByLabel(roleName)
+	if !ok {
+		return redcross.Role{}, fmt.Errorf("failed to find any role named '%s'", roleName)
+	}
+	return role, nil
+}
+
+func (p *PegassClient) getRoles(ctx context.Context) ([]redcross.Role, error) {
+	return cached(ctx, p, cache.KindRoles, "all", func() ([]redcross.Role, error) {
+		var roles []redcross.Role
+
+		err := p.init()
+		if err != nil {
+			return nil, err
+		}
+
+		getRequest, err := p.get(ctx, p.pegassURL("/crf/rest/roles"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request to Pegass 'competences' endpoint: %w", err)
+		}
+		defer getRequest.Body.Close()
+
+		err = decodeResponse(getRequest, &roles)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal search results: %w", err)
+		}
+		return roles, nil
+	})
+}
+
+func (p *PegassClient) FindRoleByName(roleName string) (redcross.Role, error) {
+	return p.FindRoleByNameContext(context.Background(), roleName)
+}
+
+// inscriptionDetails gathers what describeCrew needs to know about a registered user.
+type inscriptionDetails struct {
+	user                   redcross.Utilisateur
+	phoneNumber            string
+	isFormerFirstResponder bool
+}
+
+func (p *PegassClient) fetchInscriptionDetails(ctx context.Context, inscription redcross.Inscription) (inscriptionDetails, error) {
+	var details inscriptionDetails
+	var err error
+	details.user, err = p.GetUserDetailsContext(ctx, inscription.Utilisateur.ID)
+	if err != nil {
+		return details, err
+	}
+	details.phoneNumber, err = p.GetMainMoyenComForUserContext(ctx, inscription.Utilisateur.ID)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("failed to fetch phone number of user '%s'", inscription.Utilisateur.ID)
+	}
+	if p.crewRules().NeedsTrainings(inscription.Role) {
+		details.isFormerFirstResponder, err = p.IsFormerFirstResponderContext(ctx, inscription.Utilisateur.ID)
+		if err != nil {
+			logging.FromContext(ctx).Warnf("failed to check whether user '%s' used to be a first responder: %v", inscription.Utilisateur.ID, err)
+		}
+	}
+	return details, nil
+}
+
+// getInscriptions lists the volunteers registered to a seance.
+func (p *PegassClient) getInscriptions(ctx context.Context, seanceID string) (redcross.InscriptionList, error) {
+	inscriptions := redcross.InscriptionList{}
+	err := p.getJSON(ctx, p.pegassURL(fmt.Sprintf("/crf/rest/seance/%s/inscription", seanceID)), &inscriptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch inscriptions: %w", err)
+	}
+	return inscriptions, nil
+}
+
+// FindActivitiesOnDayContext summarizes the activities of a kind on a given day, as sent by the WhatsApp bot.
+func (p *PegassClient) FindActivitiesOnDayContext(ctx context.Context, day string, kind ActivityKind, shouldCensorData bool) (string, error) {
+	daySummary, err := p.SummarizeDayContext(ctx, day, kind, shouldCensorData)
+	if err != nil {
+		return "", err
+	}
+	return summary.RenderWhatsApp(daySummary)
+}
+
+func (p *PegassClient) FindActivitiesOnDay(day string, kind ActivityKind, shouldCensorData bool) (string, error) {
+	return p.FindActivitiesOnDayContext(context.Background(), day, kind, shouldCensorData)
+}
+
+// findActivitiesOnDay returns the activities of the given actions having a seance on the given day.
+func (p *PegassClient) findActivitiesOnDay(ctx context.Context, day string, actions []int) ([]redcross.Activity, error) {
+	err := p.init()
+	if err != nil {
+		return nil, err
+	}
+
+	var seances []redcross.Seance
+	for _, action := range actions {
+		query := url.Values{}
+		query.Add("action", strconv.Itoa(action))
+		query.Add("debut", day)
+		query.Add("fin", day)
+		query.Add("size", "100")
+		p.Zone().addTo(query)
+
+		paginator, err := p.SearchSeancesContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		actionSeances, err := paginator.Collect()
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for activities: %w", err)
+		}
+		seances = append(seances, actionSeances...)
+	}
+
+	return mapConcurrently(ctx, p.concurrency, seances, func(ctx context.Context, seance redcross.Seance) (redcross.Activity, error) {
+		activity, err := p.fetchActivityById(ctx, seance.Activite.ID)
+		if err != nil {
+			logging.FromContext(ctx).Warnf("unable to map seance '%s' to activity: %s", seance.ID, err)
+		}
+		return activity, ctx.Err()
+	})
+}
+
+// CountIncompleteSeancesContext counts, by kind name, the seances of incomplete activities starting between
+// now and now + period.
+func (p *PegassClient) CountIncompleteSeancesContext(ctx context.Context, period time.Duration) (map[string]int, error) {
+	kinds := p.ActivityKinds()
+	var counts = make(map[string]int)
+	for _, kind := range kinds {
+		counts[kind.Name] = 0
+	}
+
+	now := time.Now()
+	end := now.Add(period)
+	lastDay := end.Format("2006-01-02")
+	seen := make(map[string]bool)
+	for day := now; day.Format("2006-01-02") <= lastDay; day = day.AddDate(0, 0, 1) {
+		activities, err := p.findActivitiesOnDay(ctx, day.Format("2006-01-02"), actionIDs(kinds))
+		if err != nil {
+			return nil, err
+		}
+		for _, act := range activities {
+			if act.Statut != summary.STATUS_INCOMPLETE {
+				continue
+			}
+			for _, seance := range act.SeanceList {
+				start := seance.Debut.Local()
+				if seen[seance.ID] || start.Before(now) || !start.Before(end) {
+					continue
+				}
+				seen[seance.ID] = true
+				for _, kind := range kinds {
+					if kind.matches(act) {
+						counts[kind.Name]++
+					}
+				}
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (p *PegassClient) fetchActivityById(ctx context.Context, activityId string) (redcross.Activity, error) {
+	return cached(ctx, p, cache.KindActivities, activityId, func() (redcross.Activity, error) {
+		activity := redcross.Activity{}
+
+		url := p.pegassURL(fmt.Sprintf("/crf/rest/activite/%s", activityId))
+		request, err := p.get(ctx, url)
+		if err != nil {
+			return activity, fmt.Errorf("failed to search for activities: %w", err)
+		}
+		defer request.Body.Close()
+
+		err = decodeResponse(request, &activity)
+		if err != nil {
+			return activity, fmt.Errorf("failed to deserialize pegass activity: %w", err)
+		}
+
+		return activity, nil
+	})
+}
+
+func (p *PegassClient) GetTrainingsForUserContext(ctx context.Context, nivol string) ([]redcross.UserTraining, error) {
+	return cached(ctx, p, cache.KindTrainings, nivol, func() ([]redcross.UserTraining, error) {
+		parse, err := url.Parse(p.pegassURL("/crf/rest/formationutilisateur"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse url to pegass: %v", err)
+		}
+
+		query := parse.Query()
+		query.Add("utilisateur", nivol)
+		parse.RawQuery = query.Encode()
+
+		request, err := p.get(ctx, parse.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch user trainings: %v", err)
+		}
+		defer request.Body.Close()
+
+		var trainings []redcross.UserTraining
+		err = decodeResponse(request, &trainings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize training list: %v", err)
+		}
+
+		return trainings, nil
+	})
+}
+
+func (p *PegassClient) GetTrainingsForUser(nivol string) ([]redcross.UserTraining, error) {
+	return p.GetTrainingsForUserContext(context.Background(), nivol)
+}
+
+func (p *PegassClient) IsFormerFirstResponderContext(ctx context.Context, nivol string) (bool, error) {
+	trainings, err := p.GetTrainingsForUserContext(ctx, nivol)
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range trainings {
+		if (t.Formation.Code == "PSE2" || t.Formation.Code == "PSE1") && !t.Formation.Recyclage {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (p *PegassClient) IsFormerFirstResponder(nivol string) (bool, error) {
+	return p.IsFormerFirstResponderContext(context.Background(), nivol)
+}
+
+// DEFAULT_EXTERNAL_ASSOCIATIONS maps the NIVOL of the volunteer responsible for the activities of partner
+// associations to the association, unless other associations are set with WithExternalAssociations.
+var DEFAULT_EXTERNAL_ASSOCIATIONS = map[string]string{
+	"01100009671G": "PCPS",
+	"01100009672H": "Malte",
+	"01100039741E": "FFSS",
+}
+
+// DEFAULT_ACTIVITY_ORDER is the order of activities in summaries, unless another one is set with
+// WithActivityOrder. Other activities come last, by name.
+var DEFAULT_ACTIVITY_ORDER = []string{"01-DAUPHIN", "02-CASTOR", "03-RUBIS", "04-SAPHIR", "05-BABETTE", "REGULATION"}
+
+// externalAssociation returns the partner association whose activities nivol is responsible for, if any.
+func (p *PegassClient) externalAssociation(nivol string) (string, bool) {
+	associations := p.externalAssociations
+	if associations == nil {
+		associations = DEFAULT_EXTERNAL_ASSOCIATIONS
+	}
+	association, ok := associations[nivol]
+	return association, ok
+}
+
+// crewRules returns the rules linting crews, lint.DEFAULT_RULES unless others are set with WithLintRules.
+func (p *PegassClient) crewRules() lint.RuleSet {
+	if p.lintRules == nil {
+		return lint.DEFAULT_RULES
+	}
+	return *p.lintRules
+}
+
+func (p *PegassClient) activityOrder() redcross.ActivityOrder {
+	if p.activityOrderNames == nil {
+		return redcross.NewActivityOrder(DEFAULT_ACTIVITY_ORDER)
+	}
+	return redcross.NewActivityOrder(p.activityOrderNames)
+}
@@ -0,0 +1,51 @@
+package pegass
+
+import (
+	"context"
+	"errors"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"strings"
+)
+
+// Ways of logging in to Pegass, as reported by metrics.
+const (
+	authFlowPassword    = "password"
+	authFlowOktaSession = "okta_session"
+)
+
+// errMFAChallenge marks errors met while verifying an MFA factor.
+var errMFAChallenge = errors.New("failed to complete MFA challenge")
+
+// recordAuthentication counts a login, and its failure, when metrics are reported.
+func (p *PegassClient) recordAuthentication(flow string, err error) {
+	if p.metrics == nil {
+		return
+	}
+	p.metrics.AuthenticationAttempts.WithLabelValues(flow).Inc()
+	if err != nil {
+		p.metrics.AuthenticationFailures.WithLabelValues(flow, authenticationFailureReason(err)).Inc()
+	}
+}
+
+// authenticationFailureReason maps a login error to a short label, keeping the number of metric series low.
+func authenticationFailureReason(err error) string {
+	var authnError *redcross.AuthnError
+	var oktaError *redcross.OktaAPIError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, redcross.ErrInvalidCredentials):
+		return "invalid_credentials"
+	case errors.As(err, &authnError):
+		return strings.ToLower(authnError.Status)
+	case errors.Is(err, errMFAChallenge):
+		return "mfa"
+	case errors.As(err, &oktaError):
+		return "okta_error"
+	case errors.Is(err, redcross.ErrPegassAuthentication):
+		return "session_rejected"
+	}
+	return "other"
+}
@@ -0,0 +1,100 @@
+package pegass
+
+import (
+	"context"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/cache"
+	"github.com/fabien-chebel/pegass-cli/logging"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"strings"
+	"time"
+)
+
+// RoleCatalog looks up the roles defined in Pegass by ID, label and type.
+type RoleCatalog struct {
+	roles []redcross.Role
+	byID  map[string]redcross.Role
+}
+
+// NewRoleCatalog indexes roles, e.g. those served by /crf/rest/roles.
+func NewRoleCatalog(roles []redcross.Role) *RoleCatalog {
+	catalog := &RoleCatalog{roles: roles, byID: make(map[string]redcross.Role, len(roles))}
+	for _, role := range roles {
+		catalog.byID[role.ID] = role
+	}
+	return catalog
+}
+
+// Roles lists every role, in the order of Pegass.
+func (c *RoleCatalog) Roles() []redcross.Role {
+	return c.roles
+}
+
+// ByID returns the role of the given code, e.g. "219".
+func (c *RoleCatalog) ByID(id string) (redcross.Role, bool) {
+	role, ok := c.byID[id]
+	return role, ok
+}
+
+// ByLabel returns the role of the given label, e.g. "PSE2", case being ignored.
+func (c *RoleCatalog) ByLabel(label string) (redcross.Role, bool) {
+	for _, role := range c.roles {
+		if strings.EqualFold(role.Libelle, label) {
+			return role, true
+		}
+	}
+	return redcross.Role{}, false
+}
+
+// ByType lists the roles of a type, e.g. redcross.ROLE_TYPE_FORMATION.
+func (c *RoleCatalog) ByType(roleType string) []redcross.Role {
+	var roles []redcross.Role
+	for _, role := range c.roles {
+		if role.Type == roleType {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Describe names a role for humans, e.g. "Chauffeur (5)", or only gives its code when it is unknown.
+func (c *RoleCatalog) Describe(id string) string {
+	role, ok := c.ByID(id)
+	if !ok {
+		return fmt.Sprintf("unknown role (%s)", id)
+	}
+	return fmt.Sprintf("%s (%s)", role.Libelle, id)
+}
+
+// RoleCatalogContext loads the roles defined in Pegass, cached like other roles lookups, and keeps them in memory
+// until they expire from the cache, so that a long-running bot sees new roles. Failed loads are retried on the
+// next call.
+func (p *PegassClient) RoleCatalogContext(ctx context.Context) (*RoleCatalog, error) {
+	p.roleCatalogMutex.Lock()
+	defer p.roleCatalogMutex.Unlock()
+	if p.roleCatalog != nil && time.Since(p.roleCatalogLoadedAt) < p.cacheTTL(cache.KindRoles) {
+		return p.roleCatalog, nil
+	}
+
+	roles, err := p.getRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.roleCatalog = NewRoleCatalog(roles)
+	p.roleCatalogLoadedAt = time.Now()
+	for _, id := range redcross.KNOWN_ROLES {
+		if _, ok := p.roleCatalog.ByID(id); !ok {
+			logging.FromContext(ctx).Warnf("role %s, which summaries and statistics rely on, is no longer defined in Pegass", id)
+		}
+	}
+	return p.roleCatalog, nil
+}
+
+// describeRole names a role for logs, falling back to its code when the catalog cannot be loaded.
+func (p *PegassClient) describeRole(ctx context.Context, id string) string {
+	catalog, err := p.RoleCatalogContext(ctx)
+	if err != nil {
+		return id
+	}
+	return catalog.Describe(id)
+}
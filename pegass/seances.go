@@ -0,0 +1,131 @@
+package pegass
+
+import (
+	"context"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/logging"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SeanceFilter selects the seances returned by FindSeancesContext. Zero values do not filter.
+type SeanceFilter struct {
+	// From and To are the first and last days searched.
+	From time.Time
+	To   time.Time
+	// Action is the kind of action, e.g. 65 for the first aid network.
+	Action       int
+	TypeActivite int
+	// StructureIDs keeps the seances carried out by one of these structures.
+	StructureIDs []int
+	// Statut is the status of the activity, e.g. "Incomplète". Case and accents are ignored.
+	Statut string
+	// Label keeps the activities whose name contains it, ignoring case.
+	Label string
+}
+
+// SeanceRow describes a seance and how many volunteers registered to it.
+type SeanceRow struct {
+	ID           string    `json:"id"`
+	ActivityID   string    `json:"activity_id"`
+	Activity     string    `json:"activity"`
+	TypeActivite string    `json:"type_activite"`
+	StructureID  int       `json:"structure_id"`
+	Structure    string    `json:"structure"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Status       string    `json:"status"`
+	Registered   int       `json:"registered"`
+	// Required is the number of volunteers needed, according to the active roles of the seance.
+	Required int `json:"required"`
+}
+
+// FindSeancesContext lists the seances of the client's zone matching filter, sorted by start time.
+func (p *PegassClient) FindSeancesContext(ctx context.Context, filter SeanceFilter) ([]SeanceRow, error) {
+	query := url.Values{}
+	query.Add("debut", filter.From.Format("2006-01-02"))
+	query.Add("fin", filter.To.Format("2006-01-02"))
+	query.Add("size", "100")
+	if filter.Action != 0 {
+		query.Add("action", strconv.Itoa(filter.Action))
+	}
+	if filter.TypeActivite != 0 {
+		query.Add("typeActivite", strconv.Itoa(filter.TypeActivite))
+	}
+	p.Zone().addTo(query)
+
+	paginator, err := p.SearchSeancesContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	seances, err := paginator.Collect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for seances: %w", err)
+	}
+
+	var label = strings.ToLower(filter.Label)
+	seances = slices.DeleteFunc(seances, func(seance redcross.Seance) bool {
+		return !strings.Contains(strings.ToLower(seance.Activite.Libelle), label)
+	})
+
+	rows, err := mapConcurrently(ctx, p.concurrency, seances, p.describeSeance)
+	if err != nil {
+		return nil, err
+	}
+	rows = slices.DeleteFunc(rows, func(row SeanceRow) bool {
+		if len(filter.StructureIDs) > 0 && !slices.Contains(filter.StructureIDs, row.StructureID) {
+			return true
+		}
+		return filter.Statut != "" && normalizeStatus(row.Status) != normalizeStatus(filter.Statut)
+	})
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Start.Before(rows[j].Start)
+	})
+	return rows, nil
+}
+
+func (p *PegassClient) describeSeance(ctx context.Context, seance redcross.Seance) (SeanceRow, error) {
+	row := SeanceRow{
+		ID:         seance.ID,
+		ActivityID: seance.Activite.ID,
+		Activity:   seance.Activite.Libelle,
+		Start:      seance.Debut.Local(),
+		End:        seance.Fin.Local(),
+	}
+	for _, roleConfig := range seance.RoleConfigList {
+		if roleConfig.Actif {
+			row.Required += roleConfig.Effectif
+		}
+	}
+
+	activity, err := p.fetchActivityById(ctx, seance.Activite.ID)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("unable to map seance '%s' to activity: %s", seance.ID, err)
+		return row, ctx.Err()
+	}
+	row.TypeActivite = activity.TypeActivite.Libelle
+	row.StructureID = activity.StructureMenantActivite.ID
+	row.Structure = shortStructureName(activity.StructureMenantActivite.Libelle)
+	row.Status = activity.Statut
+
+	inscriptions, err := p.getInscriptions(ctx, seance.ID)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("unable to count registrations to seance '%s': %s", seance.ID, err)
+		return row, ctx.Err()
+	}
+	row.Registered = len(inscriptions)
+	return row, nil
+}
+
+var accents = strings.NewReplacer("é", "e", "è", "e", "ê", "e", "É", "e", "È", "e")
+
+// normalizeStatus ignores case and accents, so that "incomplete" matches "Incomplète".
+func normalizeStatus(status string) string {
+	return strings.ToLower(accents.Replace(strings.TrimSpace(status)))
+}
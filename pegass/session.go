@@ -0,0 +1,163 @@
+package pegass
+
+import (
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/vault"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// sessionJar is a cookie jar that remembers the attributes (expiry, path, domain...) of the cookies it
+// receives, which the standard cookiejar does not expose, so that the session can be persisted.
+type sessionJar struct {
+	*cookiejar.Jar
+	mutex   sync.Mutex
+	cookies map[string]map[string]*http.Cookie
+}
+
+func newSessionJar() (*sessionJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionJar{
+		Jar:     jar,
+		cookies: make(map[string]map[string]*http.Cookie),
+	}, nil
+}
+
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	hostCookies, ok := j.cookies[u.Host]
+	if !ok {
+		hostCookies = make(map[string]*http.Cookie)
+		j.cookies[u.Host] = hostCookies
+	}
+	now := time.Now()
+	for _, cookie := range cookies {
+		if cookie.MaxAge < 0 {
+			delete(hostCookies, cookie.Name)
+			continue
+		}
+		recorded := *cookie
+		if cookie.MaxAge > 0 {
+			recorded.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		hostCookies[cookie.Name] = &recorded
+	}
+}
+
+// export returns the cookies the jar would currently send to the given URL, along with the earliest
+// expiry date among them (zero if none of them expires).
+func (j *sessionJar) export(u *url.URL) ([]vault.Cookie, time.Time) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	var cookies []vault.Cookie
+	var expiresAt time.Time
+	for _, cookie := range j.Jar.Cookies(u) {
+		exported := vault.Cookie{
+			Name:  cookie.Name,
+			Value: cookie.Value,
+		}
+		if recorded, ok := j.cookies[u.Host][cookie.Name]; ok {
+			exported.Domain = recorded.Domain
+			exported.Path = recorded.Path
+			exported.Expires = recorded.Expires
+			exported.Secure = recorded.Secure
+			exported.HttpOnly = recorded.HttpOnly
+			if !recorded.Expires.IsZero() && (expiresAt.IsZero() || recorded.Expires.Before(expiresAt)) {
+				expiresAt = recorded.Expires
+			}
+		}
+		cookies = append(cookies, exported)
+	}
+	return cookies, expiresAt
+}
+
+// restore loads previously exported cookies back into the jar.
+func (j *sessionJar) restore(u *url.URL, cookies []vault.Cookie) {
+	var httpCookies []*http.Cookie
+	for _, cookie := range cookies {
+		path := cookie.Path
+		if path == "" {
+			path = "/"
+		}
+		httpCookies = append(httpCookies, &http.Cookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     path,
+			Expires:  cookie.Expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		})
+	}
+	j.SetCookies(u, httpCookies)
+}
+
+// exportSession captures the current Pegass and Okta cookies as a persistable session.
+func (p *PegassClient) exportSession(nivol string) (*vault.Session, error) {
+	pegassUrl, err := url.Parse(p.pegassURL(""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pegass URL: %w", err)
+	}
+	oktaUrl, err := url.Parse(p.oktaURL(""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse okta URL: %w", err)
+	}
+
+	session := &vault.Session{
+		Nivol:    nivol,
+		IssuedAt: time.Now(),
+	}
+	jar := p.jar()
+	session.PegassCookies, session.ExpiresAt = jar.export(pegassUrl)
+	session.OktaCookies, session.OktaExpiresAt = jar.export(oktaUrl)
+	if len(session.PegassCookies) == 0 {
+		return nil, fmt.Errorf("no cookie was set for Pegass domain")
+	}
+	return session, nil
+}
+
+// restoreSession loads the saved session into a fresh cookie jar. Pegass cookies known to
+// have expired are not restored, while Okta cookies are kept as they may allow a login without MFA.
+func (p *PegassClient) restoreSession() (*vault.Session, error) {
+	if p.sessionStore == nil {
+		return nil, fmt.Errorf("no session store configured to read authentication data from")
+	}
+	session := p.sessionStore.Session()
+	if session == nil {
+		return nil, fmt.Errorf("no Pegass session found in session store")
+	}
+
+	jar, err := newSessionJar()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+	pegassUrl, err := url.Parse(p.pegassURL(""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pegass url: %w", err)
+	}
+	oktaUrl, err := url.Parse(p.oktaURL(""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse okta url: %w", err)
+	}
+
+	now := time.Now()
+	if !session.Expired(now) {
+		jar.restore(pegassUrl, session.PegassCookies)
+	}
+	if !session.OktaExpired(now) {
+		jar.restore(oktaUrl, session.OktaCookies)
+	}
+
+	p.setJar(jar)
+	return session, p.init()
+}
@@ -0,0 +1,141 @@
+package pegass
+
+import (
+	"context"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/cache"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"sort"
+	"strconv"
+)
+
+// MAX_STRUCTURE_DEPTH bounds the walk up the hierarchy, in case Pegass returns a cycle.
+const MAX_STRUCTURE_DEPTH = 10
+
+// StructureRef names another structure.
+type StructureRef struct {
+	ID    int    `json:"id"`
+	Label string `json:"label"`
+}
+
+// StructureNode is a structure of the Pegass hierarchy (national, region, department, local unit), along with
+// the structures below it.
+type StructureNode struct {
+	ID         int    `json:"id"`
+	Type       string `json:"type"`
+	Label      string `json:"label"`
+	ShortLabel string `json:"short_label"`
+	Address    string `json:"address,omitempty"`
+	Phone      string `json:"phone,omitempty"`
+	Mail       string `json:"mail,omitempty"`
+	Website    string `json:"website,omitempty"`
+	ParentID   int    `json:"parent_id,omitempty"`
+	// CarriedOutBy lists the structures carrying out the activities of this one.
+	CarriedOutBy []StructureRef `json:"carried_out_by,omitempty"`
+	// CarriesOutFor lists the structures of the tree whose activities this one carries out.
+	CarriesOutFor []StructureRef   `json:"carries_out_for,omitempty"`
+	Children      []*StructureNode `json:"children,omitempty"`
+}
+
+func newStructureNode(structure redcross.Structure) *StructureNode {
+	node := &StructureNode{
+		ID:         structure.ID,
+		Type:       structure.TypeStructure,
+		Label:      structure.Libelle,
+		ShortLabel: structure.LibelleCourt,
+		Address:    structure.Adresse,
+		Phone:      structure.Telephone,
+		Mail:       structure.Mail,
+		Website:    structure.SiteWeb,
+		ParentID:   structure.Parent.ID,
+	}
+	if node.ShortLabel == "" {
+		node.ShortLabel = shortStructureName(structure.Libelle)
+	}
+	for _, carrier := range structure.StructureMenantActiviteList {
+		if carrier.ID != structure.ID {
+			node.CarriedOutBy = append(node.CarriedOutBy, StructureRef{ID: carrier.ID, Label: carrier.Libelle})
+		}
+	}
+	return node
+}
+
+func (p *PegassClient) GetStructureContext(ctx context.Context, id int) (redcross.Structure, error) {
+	return cached(ctx, p, cache.KindStructures, "structure/"+strconv.Itoa(id), func() (redcross.Structure, error) {
+		var structure redcross.Structure
+		err := p.getJSON(ctx, p.pegassURL(fmt.Sprintf("/crf/rest/structure/%d", id)), &structure)
+		if err != nil {
+			return structure, fmt.Errorf("failed to fetch structure '%d': %w", id, err)
+		}
+		return structure, nil
+	})
+}
+
+// GetStructureTreeContext returns the hierarchy of the structures of a zone, from the national structure down
+// to local units. Structures above the zone are included, without their other children.
+func (p *PegassClient) GetStructureTreeContext(ctx context.Context, zone Zone) ([]*StructureNode, error) {
+	err := p.init()
+	if err != nil {
+		return nil, err
+	}
+
+	structures, err := p.GetStructuresForZoneContext(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for id := range structures {
+		ids = append(ids, id)
+	}
+
+	var nodes = make(map[int]*StructureNode)
+	for depth := 0; len(ids) > 0 && depth < MAX_STRUCTURE_DEPTH; depth++ {
+		fetched, err := mapConcurrently(ctx, p.concurrency, ids, p.GetStructureContext)
+		if err != nil {
+			return nil, err
+		}
+
+		// Walk up to the parents which are not known yet
+		var parents = make(map[int]bool)
+		for _, structure := range fetched {
+			nodes[structure.ID] = newStructureNode(structure)
+		}
+		for _, structure := range fetched {
+			if structure.Parent.ID != 0 && nodes[structure.Parent.ID] == nil {
+				parents[structure.Parent.ID] = true
+			}
+		}
+		ids = ids[:0]
+		for id := range parents {
+			ids = append(ids, id)
+		}
+	}
+
+	var roots []*StructureNode
+	for _, node := range nodes {
+		if parent, ok := nodes[node.ParentID]; ok && node.ParentID != node.ID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+		for _, carrier := range node.CarriedOutBy {
+			if carrierNode, ok := nodes[carrier.ID]; ok {
+				carrierNode.CarriesOutFor = append(carrierNode.CarriesOutFor, StructureRef{ID: node.ID, Label: node.Label})
+			}
+		}
+	}
+	sortStructureNodes(roots)
+	return roots, nil
+}
+
+func sortStructureNodes(nodes []*StructureNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	for _, node := range nodes {
+		sort.Slice(node.CarriesOutFor, func(i, j int) bool {
+			return node.CarriesOutFor[i].ID < node.CarriesOutFor[j].ID
+		})
+		sortStructureNodes(node.Children)
+	}
+}
@@ -0,0 +1,61 @@
+package pegass
+
+import (
+	"context"
+	"sync"
+)
+
+const DEFAULT_CONCURRENCY = 4
+
+// mapConcurrently calls fn on every item with at most `concurrency` calls in flight, and returns the results
+// in the order of items. The first error cancels the calls not started yet, and is returned.
+func mapConcurrently[T any, R any](ctx context.Context, concurrency int, items []T, fn func(context.Context, T) (R, error)) ([]R, error) {
+	if concurrency <= 0 {
+		concurrency = DEFAULT_CONCURRENCY
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]R, len(items))
+	indexes := make(chan int)
+	var firstErr error
+	var errOnce sync.Once
+	var wg sync.WaitGroup
+
+	for worker := 0; worker < min(concurrency, len(items)); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				result, err := fn(ctx, items[i])
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				results[i] = result
+			}
+		}()
+	}
+
+feed:
+	for i := range items {
+		select {
+		case <-ctx.Done():
+			break feed
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
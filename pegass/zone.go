@@ -0,0 +1,189 @@
+package pegass
+
+import (
+	"context"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/cache"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ZoneType tells how a Zone is delimited.
+type ZoneType string
+
+const (
+	ZONE_DEPARTMENT ZoneType = "departement"
+	ZONE_REGION     ZoneType = "region"
+	ZONE_STRUCTURES ZoneType = "structures"
+)
+
+var ZONE_TYPES = []ZoneType{ZONE_DEPARTMENT, ZONE_REGION, ZONE_STRUCTURES}
+
+// Zone is the geographic scope of searches: a department, a region, or a list of structures.
+type Zone struct {
+	Type ZoneType
+	// ID is the number of the department or region.
+	ID string
+	// StructureIDs lists the structures of a ZONE_STRUCTURES zone.
+	StructureIDs []int
+}
+
+// DEFAULT_ZONE is the Hauts-de-Seine department, which the CLI was written for.
+var DEFAULT_ZONE = Zone{Type: ZONE_DEPARTMENT, ID: "92"}
+
+// DEFAULT_STATS_ZONE is the structure whose regulation activity GetActivityStatsContext counts unless a zone
+// is set with WithZone.
+var DEFAULT_STATS_ZONE = Zone{Type: ZONE_STRUCTURES, StructureIDs: []int{97}}
+
+// ParseZone reads a zone written as "departement:92", "region:11" or "structures:97,1001". A bare number is
+// a department.
+func ParseZone(value string) (Zone, error) {
+	zoneType, id, found := strings.Cut(strings.TrimSpace(value), ":")
+	if !found {
+		zoneType, id = string(ZONE_DEPARTMENT), zoneType
+	}
+	if id == "" {
+		return Zone{}, fmt.Errorf("invalid zone '%s': missing identifier", value)
+	}
+
+	switch ZoneType(zoneType) {
+	case ZONE_DEPARTMENT, ZONE_REGION:
+		return Zone{Type: ZoneType(zoneType), ID: id}, nil
+	case ZONE_STRUCTURES:
+		var zone = Zone{Type: ZONE_STRUCTURES}
+		for _, field := range strings.Split(id, ",") {
+			structureID, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return Zone{}, fmt.Errorf("invalid structure id '%s' in zone '%s'", field, value)
+			}
+			zone.StructureIDs = append(zone.StructureIDs, structureID)
+		}
+		return zone, nil
+	}
+
+	var types []string
+	for _, t := range ZONE_TYPES {
+		types = append(types, string(t))
+	}
+	return Zone{}, fmt.Errorf("invalid zone '%s': type must be one of: %s", value, strings.Join(types, ", "))
+}
+
+// String writes the zone the way ParseZone reads it.
+func (z Zone) String() string {
+	if z.Type == ZONE_STRUCTURES {
+		var ids []string
+		for _, id := range z.StructureIDs {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		return fmt.Sprintf("%s:%s", z.Type, strings.Join(ids, ","))
+	}
+	return fmt.Sprintf("%s:%s", z.Type, z.ID)
+}
+
+// Slug identifies the zone in file names, e.g. "departement-92".
+func (z Zone) Slug() string {
+	return strings.NewReplacer(":", "-", ",", "-").Replace(z.String())
+}
+
+// addTo restricts a Pegass search to the zone.
+func (z Zone) addTo(query url.Values) {
+	if z.Type == ZONE_STRUCTURES {
+		for _, id := range z.StructureIDs {
+			query.Add("structure", strconv.Itoa(id))
+		}
+		return
+	}
+	query.Add("zoneGeoId", z.ID)
+	query.Add("zoneGeoType", string(z.Type))
+}
+
+// Zone returns the zone the client searches in, DEFAULT_ZONE unless WithZone was used.
+func (p *PegassClient) Zone() Zone {
+	if p.searchZone.Type == "" {
+		return DEFAULT_ZONE
+	}
+	return p.searchZone
+}
+
+// statsZone returns the zone regulation statistics are computed for, DEFAULT_STATS_ZONE unless WithZone was
+// used.
+func (p *PegassClient) statsZone() Zone {
+	if p.searchZone.Type == "" {
+		return DEFAULT_STATS_ZONE
+	}
+	return p.searchZone
+}
+
+// GetStructuresForZoneContext returns the names of the structures of a zone, by id. Names are empty for
+// ZONE_STRUCTURES zones, Pegass only describing the structures of departments and regions.
+func (p *PegassClient) GetStructuresForZoneContext(ctx context.Context, zone Zone) (map[int]string, error) {
+	switch zone.Type {
+	case ZONE_DEPARTMENT:
+		return p.GetStructuresForDepartmentContext(ctx, zone.ID)
+	case ZONE_REGION:
+		return p.getZoneGeoStructures(ctx, zone.Type, zone.ID)
+	case ZONE_STRUCTURES:
+		var structures = make(map[int]string)
+		for _, id := range zone.StructureIDs {
+			structures[id] = ""
+		}
+		return structures, nil
+	}
+	return nil, fmt.Errorf("unsupported zone type '%s'", zone.Type)
+}
+
+// zoneStructureIDs returns the ids of the structures of the client's zone, sorted.
+func (p *PegassClient) zoneStructureIDs(ctx context.Context) ([]int, error) {
+	structures, err := p.GetStructuresForZoneContext(ctx, p.Zone())
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for id := range structures {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// getZoneGeoStructures lists the structures of a department or region.
+func (p *PegassClient) getZoneGeoStructures(ctx context.Context, zoneType ZoneType, id string) (map[int]string, error) {
+	var key = id
+	if zoneType != ZONE_DEPARTMENT {
+		key = fmt.Sprintf("%s/%s", zoneType, id)
+	}
+	return cached(ctx, p, cache.KindStructures, key, func() (map[int]string, error) {
+		err := p.init()
+		if err != nil {
+			return nil, err
+		}
+
+		request, err := p.get(ctx, p.pegassURL(fmt.Sprintf("/crf/rest/zonegeo/%s/%s", zoneType, id)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the list of %s structures: %w", zoneType, err)
+		}
+		defer request.Body.Close()
+
+		var structureList = redcross.StructureList{}
+		err = decodeResponse(request, &structureList)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize pegass request: %w", err)
+		}
+
+		var dict = make(map[int]string)
+		for _, structure := range structureList.StructuresFilles {
+			dict[structure.ID] = shortStructureName(structure.Libelle)
+		}
+
+		return dict, nil
+	})
+}
+
+// shortStructureName drops the "UNITE LOCALE DE" prefix of local units.
+func shortStructureName(name string) string {
+	name = strings.ReplaceAll(name, "UNITE LOCALE DE ", "")
+	return strings.ReplaceAll(name, "UNITE LOCALE D'", "")
+}
@@ -0,0 +1,55 @@
+package pegass
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseZone(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Zone
+		query    string
+		err      string
+	}{
+		{value: "92", expected: Zone{Type: ZONE_DEPARTMENT, ID: "92"}, query: "zoneGeoId=92&zoneGeoType=departement"},
+		{value: "departement:2A", expected: Zone{Type: ZONE_DEPARTMENT, ID: "2A"}, query: "zoneGeoId=2A&zoneGeoType=departement"},
+		{value: " region:11 ", expected: Zone{Type: ZONE_REGION, ID: "11"}, query: "zoneGeoId=11&zoneGeoType=region"},
+		{value: "structures:97", expected: Zone{Type: ZONE_STRUCTURES, StructureIDs: []int{97}}, query: "structure=97"},
+		{value: "structures:97, 1001", expected: Zone{Type: ZONE_STRUCTURES, StructureIDs: []int{97, 1001}}, query: "structure=97&structure=1001"},
+		{value: "", err: "missing identifier"},
+		{value: "region:", err: "missing identifier"},
+		{value: "structures:97,abc", err: "invalid structure id 'abc'"},
+		{value: "commune:92100", err: "type must be one of: departement, region, structures"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			zone, err := ParseZone(test.value)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing '%s', got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(zone, test.expected) {
+				t.Errorf("expected zone %+v, got %+v", test.expected, zone)
+			}
+
+			query := url.Values{}
+			zone.addTo(query)
+			if query.Encode() != test.query {
+				t.Errorf("expected query '%s', got '%s'", test.query, query.Encode())
+			}
+
+			reparsed, err := ParseZone(zone.String())
+			if err != nil || !reflect.DeepEqual(reparsed, zone) {
+				t.Errorf("expected '%s' to parse back to %+v, got %+v (%v)", zone, zone, reparsed, err)
+			}
+		})
+	}
+}
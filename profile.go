@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/urfave/cli.v1"
+	"os"
+	"path/filepath"
+)
+
+const PROFILE_ENV = "PEGASS_PROFILE"
+
+var profileName string
+
+// profileDirectory is where the files of the selected profile (configuration, vault, WhatsApp device
+// store...) live. It is the working directory when no profile is selected, for backward compatibility.
+var profileDirectory = "."
+
+func profilesRoot() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user configuration directory: %w", err)
+	}
+	return filepath.Join(configDir, "pegass-cli"), nil
+}
+
+// selectProfile resolves the directory of the profile given through --profile or PEGASS_PROFILE, and
+// creates it if necessary.
+func selectProfile() error {
+	if profileName == "" {
+		profileName = os.Getenv(PROFILE_ENV)
+	}
+	if profileName == "" {
+		return nil
+	}
+	if profileName != filepath.Base(profileName) || profileName == ".." {
+		return fmt.Errorf("invalid profile name '%s'", profileName)
+	}
+
+	root, err := profilesRoot()
+	if err != nil {
+		return err
+	}
+	profileDirectory = filepath.Join(root, profileName)
+	err = os.MkdirAll(profileDirectory, 0700)
+	if err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	log.Debugf("using profile '%s' in '%s'", profileName, profileDirectory)
+	return nil
+}
+
+func profilePath(fileName string) string {
+	return filepath.Join(profileDirectory, fileName)
+}
+
+var profilesCommand = cli.Command{
+	Name:  "profiles",
+	Usage: "List the profiles found in the profiles directory",
+	Action: func(c *cli.Context) error {
+		root, err := profilesRoot()
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(root)
+		if os.IsNotExist(err) {
+			log.Infof("No profile found in '%s'", root)
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to list profiles: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				fmt.Printf("%s\t%s\n", entry.Name(), filepath.Join(root, entry.Name()))
+			}
+		}
+		return nil
+	},
+}
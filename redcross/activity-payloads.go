@@ -44,7 +44,9 @@ type Seance struct {
 	} `json:"roleConfigList"`
 }
 
-type InscriptionList []struct {
+type InscriptionList []Inscription
+
+type Inscription struct {
 	ID       string `json:"id"`
 	Activite struct {
 		ID           string `json:"id"`
@@ -102,23 +104,39 @@ type Action struct {
 	Libelle string `json:"libelle"`
 }
 
-type ByActivity []Activity
+// ActivityOrder ranks activities by name, e.g. ambulance call signs, in the order summaries list them.
+type ActivityOrder map[string]int
+
+// NewActivityOrder ranks names in the given order.
+func NewActivityOrder(names []string) ActivityOrder {
+	order := make(ActivityOrder, len(names))
+	for i, name := range names {
+		order[name] = i + 1
+	}
+	return order
+}
+
+// ByActivity sorts activities by the rank of their name in Order, then by start.
+type ByActivity struct {
+	Activities []Activity
+	Order      ActivityOrder
+}
 
 func (a ByActivity) Len() int {
-	return len(a)
+	return len(a.Activities)
 }
 
 func (a ByActivity) Swap(i, j int) {
-	a[i], a[j] = a[j], a[i]
+	a.Activities[i], a.Activities[j] = a.Activities[j], a.Activities[i]
 }
 
 func (a ByActivity) Less(i, j int) bool {
-	// DAUPHIN < CASTOR < RUBIS < SAPHIR < BABETTE < REGULATION
-	var first = a[i]
-	var second = a[j]
+	// e.g. DAUPHIN < CASTOR < RUBIS < SAPHIR < BABETTE < REGULATION
+	var first = a.Activities[i]
+	var second = a.Activities[j]
 
-	firstIdx, firstKnown := ACTIVITY_MAP[first.Libelle]
-	secondIdx, secondKnown := ACTIVITY_MAP[second.Libelle]
+	firstIdx, firstKnown := a.Order[first.Libelle]
+	secondIdx, secondKnown := a.Order[second.Libelle]
 
 	// First, check whether we can map activities to known activity names.
 	// If any of the activity is not known, using the following rules:
@@ -146,9 +164,8 @@ func (a ByActivity) Less(i, j int) bool {
 
 }
 
-var ACTIVITY_MAP = map[string]int{"01-DAUPHIN": 1,
-	"02-CASTOR": 2, "03-RUBIS": 3, "04-SAPHIR": 4,
-	"05-BABETTE": 5, "REGULATION": 6}
+// PEGASS_TIME_LAYOUT is the format of the dates sent by Pegass.
+const PEGASS_TIME_LAYOUT = "2006-01-02T15:04:05"
 
 type PegassTime time.Time
 
@@ -158,8 +175,7 @@ func (p *PegassTime) UnmarshalJSON(b []byte) error {
 		return nil
 	}
 
-	const layout = "2006-01-02T15:04:05"
-	t, err := time.Parse(layout, value)
+	t, err := time.Parse(PEGASS_TIME_LAYOUT, value)
 	if err != nil {
 		return err
 	}
@@ -167,8 +183,23 @@ func (p *PegassTime) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+func (p PegassTime) MarshalJSON() ([]byte, error) {
+	t := time.Time(p)
+	if t.IsZero() {
+		return []byte(`""`), nil
+	}
+	return []byte(`"` + t.Format(PEGASS_TIME_LAYOUT) + `"`), nil
+}
+
 func (p *PegassTime) PrintTimePart() string {
 	t := time.Time(*p)
 	return t.Format("15:04")
 
 }
+
+// Local returns the time in the local time zone: Pegass does not tell the time zone of its dates, which are
+// French local times.
+func (p PegassTime) Local() time.Time {
+	t := time.Time(p)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+}
@@ -14,29 +14,61 @@ type PasswordAuthOptions struct {
 }
 
 type MFAAuthRequest struct {
-	PassCode   string `json:"passCode"`
+	PassCode   string `json:"passCode,omitempty"`
 	StateToken string `json:"stateToken"`
 }
 
 type MFAAuthResponse struct {
+	StateToken   string `json:"stateToken"`
 	Status       string `json:"status"`
 	SessionToken string `json:"sessionToken"`
+	FactorResult string `json:"factorResult"`
+	Links        Links  `json:"_links"`
+}
+
+type Links struct {
+	Next *Link `json:"next,omitempty"`
+	Skip *Link `json:"skip,omitempty"`
+}
+
+type Link struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
 }
 
 type PasswordAuthResponse struct {
-	StateToken string    `json:"stateToken"`
-	ExpiresAt  time.Time `json:"expiresAt"`
-	Status     string    `json:"status"`
-	Embedded   Embedded  `json:"_embedded"`
+	StateToken   string    `json:"stateToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	Status       string    `json:"status"`
+	SessionToken string    `json:"sessionToken"`
+	Embedded     Embedded  `json:"_embedded"`
+	Links        Links     `json:"_links"`
 }
 
 type Factors struct {
-	ID         string `json:"id"`
-	FactorType string `json:"factorType"`
-	Provider   string `json:"provider"`
-	VendorName string `json:"vendorName"`
+	ID         string        `json:"id"`
+	FactorType string        `json:"factorType"`
+	Provider   string        `json:"provider"`
+	VendorName string        `json:"vendorName"`
+	Profile    FactorProfile `json:"profile"`
+}
+
+type FactorProfile struct {
+	PhoneNumber string `json:"phoneNumber,omitempty"`
+	Name        string `json:"name,omitempty"`
 }
 
 type Embedded struct {
 	Factors []Factors `json:"factors"`
+	Policy  Policy    `json:"policy"`
+}
+
+type Policy struct {
+	Expiration struct {
+		PasswordExpireDays int `json:"passwordExpireDays"`
+	} `json:"expiration"`
+}
+
+type StateTokenRequest struct {
+	StateToken string `json:"stateToken"`
 }
@@ -0,0 +1,109 @@
+package redcross
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Okta authentication transaction states, see https://developer.okta.com/docs/reference/api/authn/#transaction-state
+const (
+	AuthnStatusUnauthenticated    = "UNAUTHENTICATED"
+	AuthnStatusPasswordWarn       = "PASSWORD_WARN"
+	AuthnStatusPasswordExpired    = "PASSWORD_EXPIRED"
+	AuthnStatusRecovery           = "RECOVERY"
+	AuthnStatusRecoveryChallenge  = "RECOVERY_CHALLENGE"
+	AuthnStatusPasswordReset      = "PASSWORD_RESET"
+	AuthnStatusLockedOut          = "LOCKED_OUT"
+	AuthnStatusMFAEnroll          = "MFA_ENROLL"
+	AuthnStatusMFAEnrollActivate  = "MFA_ENROLL_ACTIVATE"
+	AuthnStatusMFARequired        = "MFA_REQUIRED"
+	AuthnStatusMFAChallenge       = "MFA_CHALLENGE"
+	AuthnStatusSuccess            = "SUCCESS"
+	oktaErrorAuthenticationFailed = "E0000004"
+	oktaErrorUserLocked           = "E0000069"
+)
+
+// AuthnError is returned when an Okta authentication transaction ends up in a state that the CLI cannot
+// complete on its own. Use errors.Is with the Err* values below to check for a given state.
+type AuthnError struct {
+	Status  string
+	Message string
+}
+
+func (e *AuthnError) Error() string {
+	return fmt.Sprintf("okta authentication failed (%s): %s", e.Status, e.Message)
+}
+
+func (e *AuthnError) Is(target error) bool {
+	authnError, ok := target.(*AuthnError)
+	return ok && authnError.Status == e.Status
+}
+
+var (
+	ErrInvalidCredentials = &AuthnError{Status: AuthnStatusUnauthenticated, Message: "invalid username or password"}
+	ErrPasswordExpired    = &AuthnError{Status: AuthnStatusPasswordExpired, Message: "the password has expired and must be changed on the Red Cross portal"}
+	ErrLockedOut          = &AuthnError{Status: AuthnStatusLockedOut, Message: "the account is locked out after too many failed attempts and must be unlocked by an administrator"}
+	ErrMFAEnroll          = &AuthnError{Status: AuthnStatusMFAEnroll, Message: "no MFA factor is enrolled, please enroll one on the Red Cross portal"}
+	ErrMFAEnrollActivate  = &AuthnError{Status: AuthnStatusMFAEnrollActivate, Message: "the MFA factor enrollment must be activated on the Red Cross portal"}
+	ErrRecovery           = &AuthnError{Status: AuthnStatusRecovery, Message: "a password recovery is in progress and must be completed on the Red Cross portal"}
+	ErrRecoveryChallenge  = &AuthnError{Status: AuthnStatusRecoveryChallenge, Message: "a password recovery challenge must be completed on the Red Cross portal"}
+	ErrPasswordReset      = &AuthnError{Status: AuthnStatusPasswordReset, Message: "the password must be reset on the Red Cross portal"}
+)
+
+// NewAuthnError maps an Okta transaction state the CLI cannot handle to its typed error.
+func NewAuthnError(status string) error {
+	for _, err := range []*AuthnError{ErrPasswordExpired, ErrLockedOut, ErrMFAEnroll, ErrMFAEnrollActivate, ErrRecovery, ErrRecoveryChallenge, ErrPasswordReset} {
+		if err.Status == status {
+			return err
+		}
+	}
+	return &AuthnError{Status: status, Message: "unexpected authentication state"}
+}
+
+// IsAccountUnusable reports whether the error requires a human to fix the account (new password,
+// unlock, MFA enrollment...) before any login can succeed again.
+func IsAccountUnusable(err error) bool {
+	for _, target := range []error{ErrInvalidCredentials, ErrPasswordExpired, ErrLockedOut, ErrMFAEnroll, ErrMFAEnrollActivate, ErrRecovery, ErrRecoveryChallenge, ErrPasswordReset} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// PasswordWarning is reported when Okta warns that the password is about to expire. The login goes on.
+type PasswordWarning struct {
+	DaysLeft int
+}
+
+func (w *PasswordWarning) Error() string {
+	return fmt.Sprintf("okta password expires in %d day(s)", w.DaysLeft)
+}
+
+// OktaAPIError is the error payload returned by Okta along with a 4xx or 5xx status code.
+type OktaAPIError struct {
+	StatusCode   int    `json:"-"`
+	ErrorCode    string `json:"errorCode"`
+	ErrorSummary string `json:"errorSummary"`
+	ErrorCauses  []struct {
+		ErrorSummary string `json:"errorSummary"`
+	} `json:"errorCauses"`
+}
+
+func (e *OktaAPIError) Error() string {
+	message := fmt.Sprintf("okta returned status %d (%s): %s", e.StatusCode, e.ErrorCode, e.ErrorSummary)
+	for _, cause := range e.ErrorCauses {
+		message += "; " + cause.ErrorSummary
+	}
+	return message
+}
+
+func (e *OktaAPIError) Is(target error) bool {
+	switch e.ErrorCode {
+	case oktaErrorAuthenticationFailed:
+		return target == ErrInvalidCredentials
+	case oktaErrorUserLocked:
+		return target == ErrLockedOut
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package redcross
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrPegassAuthentication is matched by errors.Is when Pegass answers with the login page, or redirects to
+// it, instead of JSON: the session has expired or was revoked.
+var ErrPegassAuthentication = errors.New("pegass session is not authenticated")
+
+// PegassAPIError is returned when Pegass answers a request with an error status, or with something else
+// than the expected JSON document.
+type PegassAPIError struct {
+	Method      string
+	Endpoint    string
+	StatusCode  int
+	ContentType string
+	// RedirectedTo is the URL that finally answered, when Pegass redirected the request elsewhere.
+	RedirectedTo string
+	// Body is the beginning of the response body.
+	Body string
+	// Err is the decoding error, when the status was successful but the body could not be decoded.
+	Err error
+}
+
+func (e *PegassAPIError) Error() string {
+	var message = fmt.Sprintf("pegass %s %s returned status %d (%s)", e.Method, e.Endpoint, e.StatusCode, e.ContentType)
+	if e.RedirectedTo != "" {
+		message += fmt.Sprintf(" after a redirection to %s", e.RedirectedTo)
+	}
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	if e.Body != "" {
+		message += fmt.Sprintf(": %q", e.Body)
+	}
+	return message
+}
+
+func (e *PegassAPIError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PegassAPIError) Is(target error) bool {
+	return target == ErrPegassAuthentication && e.IsAuthenticationFailure()
+}
+
+// IsAuthenticationFailure reports whether Pegass refused the session: an authentication status, a
+// redirection, or an HTML page (the login form) where JSON was expected.
+func (e *PegassAPIError) IsAuthenticationFailure() bool {
+	if e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden {
+		return true
+	}
+	if e.StatusCode >= http.StatusMultipleChoices && e.StatusCode < http.StatusBadRequest {
+		return true
+	}
+	if e.RedirectedTo != "" {
+		return true
+	}
+	// Error pages of proxies are HTML too, but come with a 5xx status
+	return e.StatusCode < http.StatusMultipleChoices && strings.HasPrefix(e.ContentType, "text/html")
+}
@@ -0,0 +1,36 @@
+package redcross
+
+// Types of roles: skills, appointments and trainings.
+const (
+	ROLE_TYPE_COMPETENCE = "COMP"
+	ROLE_TYPE_NOMINATION = "NOMI"
+	ROLE_TYPE_FORMATION  = "FORM"
+)
+
+// Pegass codes of the roles volunteers register to seances as. Their labels are served by /crf/rest/roles, and
+// looked up through the role catalog of the pegass package. The codes stay hardcoded because they tell what part
+// a role plays, e.g. crew chief, which the catalog does not: labels are edited in Pegass, while codes never
+// change. KNOWN_ROLES are checked against the catalog when it is loaded.
+const (
+	ROLE_PARTICIPANT_OPR      = "1"   // Participant, counted as a radio operator in dispatch stats
+	ROLE_DRIVER               = "5"   // Chauffeur
+	ROLE_DISPATCHER           = "18"  // Régulateur
+	ROLE_OPR_TRAINING         = "47"  // FORM OPR
+	ROLE_DISPATCHER_EVALUATOR = "63"  // Evaluateur régulateur
+	ROLE_DISPATCHER_ASSISTANT = "80"  // Aide-Régulateur
+	ROLE_CHIEF_NETWORK        = "110" // CI Réseau de secours
+	ROLE_CHIEF_BSPP           = "111" // CI BSPP
+	ROLE_ARS_EVALUATOR        = "134" // Evaluateur ARS
+	ROLE_RADIO_OPERATOR       = "198" // Opérateur radio
+	ROLE_PARTICIPANT          = "200" // Participant
+	ROLE_PSE1                 = "215"
+	ROLE_PSE2                 = "219"
+	ROLE_ARS                  = "227"
+)
+
+// KNOWN_ROLES lists the roles the CLI relies on.
+var KNOWN_ROLES = []string{
+	ROLE_PARTICIPANT_OPR, ROLE_DRIVER, ROLE_DISPATCHER, ROLE_OPR_TRAINING, ROLE_DISPATCHER_EVALUATOR,
+	ROLE_DISPATCHER_ASSISTANT, ROLE_CHIEF_NETWORK, ROLE_CHIEF_BSPP, ROLE_ARS_EVALUATOR, ROLE_RADIO_OPERATOR,
+	ROLE_PARTICIPANT, ROLE_PSE1, ROLE_PSE2, ROLE_ARS,
+}
@@ -5,11 +5,12 @@ type GestionDesDroits struct {
 }
 
 type RechercheBenevoles struct {
-	List    []Utilisateur `json:"content"`
-	Page    int           `json:"number"`
-	Total   int           `json:"totalPages"`
-	Perpage int           `json:"perpage"`
-	Last    bool          `json:"last"`
+	List          []Utilisateur `json:"content"`
+	Page          int           `json:"number"`
+	Total         int           `json:"totalPages"`
+	Perpage       int           `json:"perpage"`
+	Last          bool          `json:"last"`
+	TotalElements int           `json:"totalElements"`
 }
 
 type Structure struct {
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/pegass"
+	"gopkg.in/urfave/cli.v1"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+const REFERENCE_DATA_FILE = "reference-data.json"
+
+// ReferenceData is what Pegass does not tell: the order of activities in summaries, and which volunteers are
+// responsible for the activities of partner associations. It is kept in 'reference-data.json', next to
+// 'config.json', and defaults to pegass.DEFAULT_ACTIVITY_ORDER and pegass.DEFAULT_EXTERNAL_ASSOCIATIONS.
+type ReferenceData struct {
+	ActivityOrder []string      `json:"activity_order"`
+	Associations  []Association `json:"associations"`
+}
+
+// Association is a partner association, whose activities are run by the volunteer of the given NIVOL.
+type Association struct {
+	NIVOL string `json:"nivol"`
+	Name  string `json:"name"`
+}
+
+func defaultReferenceData() ReferenceData {
+	data := ReferenceData{ActivityOrder: slices.Clone(pegass.DEFAULT_ACTIVITY_ORDER), Associations: []Association{}}
+	for _, nivol := range slices.Sorted(maps.Keys(pegass.DEFAULT_EXTERNAL_ASSOCIATIONS)) {
+		data.Associations = append(data.Associations, Association{NIVOL: nivol, Name: pegass.DEFAULT_EXTERNAL_ASSOCIATIONS[nivol]})
+	}
+	return data
+}
+
+func loadReferenceData() (ReferenceData, error) {
+	content, err := os.ReadFile(profilePath(REFERENCE_DATA_FILE))
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultReferenceData(), nil
+	} else if err != nil {
+		return ReferenceData{}, fmt.Errorf("failed to read reference data: %w", err)
+	}
+
+	var data ReferenceData
+	err = json.Unmarshal(content, &data)
+	if err != nil {
+		return data, fmt.Errorf("failed to parse reference data file '%s': %w", profilePath(REFERENCE_DATA_FILE), err)
+	}
+	if data.ActivityOrder == nil {
+		data.ActivityOrder = []string{}
+	}
+	if data.Associations == nil {
+		data.Associations = []Association{}
+	}
+	return data, nil
+}
+
+func saveReferenceData(data ReferenceData) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize reference data: %w", err)
+	}
+	err = os.WriteFile(profilePath(REFERENCE_DATA_FILE), append(content, '\n'), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to save reference data: %w", err)
+	}
+	return nil
+}
+
+// clientOptions configures the Pegass client with the activity order and the associations.
+func (d ReferenceData) clientOptions() []pegass.Option {
+	associations := make(map[string]string)
+	for _, association := range d.Associations {
+		associations[association.NIVOL] = association.Name
+	}
+	return []pegass.Option{
+		pegass.WithActivityOrder(d.ActivityOrder),
+		pegass.WithExternalAssociations(associations),
+	}
+}
+
+var associationsCommand = cli.Command{
+	Name:  "associations",
+	Usage: "Manage the partner associations, recognized by the NIVOL of the volunteer responsible for their activities",
+	Subcommands: []cli.Command{
+		{
+			Name:  "list",
+			Usage: "List partner associations",
+			Action: func(c *cli.Context) error {
+				data, err := loadReferenceData()
+				if err != nil {
+					return err
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "NIVOL\tASSOCIATION")
+				for _, association := range data.Associations {
+					fmt.Fprintf(w, "%s\t%s\n", association.NIVOL, association.Name)
+				}
+				return w.Flush()
+			},
+		},
+		{
+			Name:      "add",
+			Usage:     "Add a partner association, or rename it",
+			ArgsUsage: "<nivol> <name>",
+			Action: func(c *cli.Context) error {
+				nivol, name := strings.TrimSpace(c.Args().Get(0)), strings.TrimSpace(c.Args().Get(1))
+				if nivol == "" || name == "" || c.NArg() != 2 {
+					return fmt.Errorf("expected a NIVOL and an association name, e.g. 'associations add 01100009672H Malte'")
+				}
+				data, err := loadReferenceData()
+				if err != nil {
+					return err
+				}
+
+				i := slices.IndexFunc(data.Associations, func(a Association) bool { return a.NIVOL == nivol })
+				if i >= 0 {
+					data.Associations[i].Name = name
+				} else {
+					data.Associations = append(data.Associations, Association{NIVOL: nivol, Name: name})
+				}
+				return saveReferenceData(data)
+			},
+		},
+		{
+			Name:      "remove",
+			Usage:     "Remove a partner association",
+			ArgsUsage: "<nivol>",
+			Action: func(c *cli.Context) error {
+				nivol := c.Args().Get(0)
+				data, err := loadReferenceData()
+				if err != nil {
+					return err
+				}
+
+				i := slices.IndexFunc(data.Associations, func(a Association) bool { return a.NIVOL == nivol })
+				if i < 0 {
+					return fmt.Errorf("no association is registered for NIVOL '%s'", nivol)
+				}
+				data.Associations = slices.Delete(data.Associations, i, i+1)
+				return saveReferenceData(data)
+			},
+		},
+	},
+}
+
+var activityOrderCommand = cli.Command{
+	Name:  "activity-order",
+	Usage: "Manage the order of activities in summaries, e.g. ambulance call signs. Other activities come last, by name",
+	Subcommands: []cli.Command{
+		{
+			Name:  "list",
+			Usage: "List activities in order",
+			Action: func(c *cli.Context) error {
+				data, err := loadReferenceData()
+				if err != nil {
+					return err
+				}
+				for i, name := range data.ActivityOrder {
+					fmt.Printf("%d\t%s\n", i+1, name)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "add",
+			Usage:     "Add an activity, at the end of the order or before another one",
+			ArgsUsage: "<activity>",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "before",
+					Usage: "activity to insert the new one before",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				name := strings.TrimSpace(c.Args().Get(0))
+				if name == "" {
+					return fmt.Errorf("expected an activity name, e.g. 'activity-order add 06-ONYX'")
+				}
+				data, err := loadReferenceData()
+				if err != nil {
+					return err
+				}
+				if slices.Contains(data.ActivityOrder, name) {
+					return fmt.Errorf("activity '%s' is already ordered", name)
+				}
+
+				position := len(data.ActivityOrder)
+				if before := c.String("before"); before != "" {
+					position = slices.Index(data.ActivityOrder, before)
+					if position < 0 {
+						return fmt.Errorf("unknown activity '%s'", before)
+					}
+				}
+				data.ActivityOrder = slices.Insert(data.ActivityOrder, position, name)
+				return saveReferenceData(data)
+			},
+		},
+		{
+			Name:      "remove",
+			Usage:     "Remove an activity from the order",
+			ArgsUsage: "<activity>",
+			Action: func(c *cli.Context) error {
+				name := c.Args().Get(0)
+				data, err := loadReferenceData()
+				if err != nil {
+					return err
+				}
+
+				i := slices.Index(data.ActivityOrder, name)
+				if i < 0 {
+					return fmt.Errorf("unknown activity '%s'", name)
+				}
+				data.ActivityOrder = slices.Delete(data.ActivityOrder, i, i+1)
+				return saveReferenceData(data)
+			},
+		},
+		{
+			Name:      "set",
+			Usage:     "Replace the whole order",
+			ArgsUsage: "<activity>...",
+			Action: func(c *cli.Context) error {
+				names := []string(c.Args())
+				sorted := slices.Sorted(slices.Values(names))
+				if len(slices.Compact(sorted)) != len(names) {
+					return fmt.Errorf("activities must be listed once each")
+				}
+				data, err := loadReferenceData()
+				if err != nil {
+					return err
+				}
+				data.ActivityOrder = names
+				return saveReferenceData(data)
+			},
+		},
+	},
+}
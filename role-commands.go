@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	redcross "github.com/fabien-chebel/pegass-cli/redcross"
+	"gopkg.in/urfave/cli.v1"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+var ROLE_TYPES = []string{redcross.ROLE_TYPE_COMPETENCE, redcross.ROLE_TYPE_NOMINATION, redcross.ROLE_TYPE_FORMATION}
+
+var rolesCommand = cli.Command{
+	Name:  "roles",
+	Usage: "Inspect the roles defined in Pegass",
+	Subcommands: []cli.Command{
+		{
+			Name:  "list",
+			Usage: "List roles with their code, type and label",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "type",
+					Usage: fmt.Sprintf("only list roles of this type (one of: %s)", strings.Join(ROLE_TYPES, ", ")),
+				},
+				cli.BoolFlag{
+					Name:  "json",
+					Usage: "print roles as JSON",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				roleType := strings.ToUpper(c.String("type"))
+				if roleType != "" && !slices.Contains(ROLE_TYPES, roleType) {
+					return fmt.Errorf("unknown role type '%s' (expected one of: %s)", c.String("type"), strings.Join(ROLE_TYPES, ", "))
+				}
+
+				ctx, cancel := commandContext()
+				defer cancel()
+				_, err := initClient(ctx)
+				if err != nil {
+					return err
+				}
+
+				catalog, err := pegassClient.RoleCatalogContext(ctx)
+				if err != nil {
+					return err
+				}
+				roles := catalog.Roles()
+				if roleType != "" {
+					roles = catalog.ByType(roleType)
+				}
+
+				if c.Bool("json") {
+					if roles == nil {
+						roles = []redcross.Role{}
+					}
+					encoder := json.NewEncoder(os.Stdout)
+					encoder.SetIndent("", "  ")
+					return encoder.Encode(roles)
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "ID\tTYPE\tLABEL")
+				for _, role := range roles {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", role.ID, role.Type, role.Libelle)
+				}
+				return w.Flush()
+			},
+		},
+	},
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/pegass"
+	"gopkg.in/urfave/cli.v1"
+	"io"
+	"os"
+	"strings"
+)
+
+var structuresCommand = cli.Command{
+	Name:  "structures",
+	Usage: "Print the hierarchy of the structures of the zone, with their contact details",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the hierarchy as JSON",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		ctx, cancel := commandContext()
+		defer cancel()
+		_, err := initClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		tree, err := pegassClient.GetStructureTreeContext(ctx, pegassClient.Zone())
+		if err != nil {
+			return err
+		}
+
+		if c.Bool("json") {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(tree)
+		}
+		for _, root := range tree {
+			printStructureNode(os.Stdout, root, "", "")
+		}
+		return nil
+	},
+}
+
+// printStructureNode prints a structure and its children as a tree. prefix starts the line of the structure,
+// and indent the lines below it.
+func printStructureNode(w io.Writer, node *pegass.StructureNode, prefix string, indent string) {
+	fmt.Fprintf(w, "%s%s [%d] %s\n", prefix, node.ShortLabel, node.ID, node.Type)
+
+	var childIndent = indent + "│   "
+	if len(node.Children) == 0 {
+		childIndent = indent + "    "
+	}
+	var details []string
+	if node.Label != node.ShortLabel {
+		details = append(details, node.Label)
+	}
+	var contact []string
+	for _, value := range []string{node.Phone, node.Mail, node.Address} {
+		if value != "" {
+			contact = append(contact, value)
+		}
+	}
+	if len(contact) > 0 {
+		details = append(details, strings.Join(contact, " | "))
+	}
+	if len(node.CarriedOutBy) > 0 {
+		details = append(details, "activités menées par : "+formatStructureRefs(node.CarriedOutBy))
+	}
+	if len(node.CarriesOutFor) > 0 {
+		details = append(details, "mène les activités de : "+formatStructureRefs(node.CarriesOutFor))
+	}
+	for _, detail := range details {
+		fmt.Fprintf(w, "%s%s\n", childIndent, detail)
+	}
+
+	for i, child := range node.Children {
+		if i == len(node.Children)-1 {
+			printStructureNode(w, child, indent+"└── ", indent+"    ")
+		} else {
+			printStructureNode(w, child, indent+"├── ", indent+"│   ")
+		}
+	}
+}
+
+func formatStructureRefs(refs []pegass.StructureRef) string {
+	var names []string
+	for _, ref := range refs {
+		names = append(names, fmt.Sprintf("%s [%d]", ref.Label, ref.ID))
+	}
+	return strings.Join(names, ", ")
+}
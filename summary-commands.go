@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/summary"
+	"gopkg.in/urfave/cli.v1"
+	"strings"
+	"time"
+)
+
+var summaryCommand = cli.Command{
+	Name:  "summary",
+	Usage: "Print the state of the first aid network activities of a day, with their crews and issues",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "day",
+			Usage: "day to summarize, e.g. 2024-06-01 (default: tomorrow)",
+		},
+		cli.StringFlag{
+			Name:  "kind",
+			Usage: "kind of activities, as named in config.json, e.g. 'samu' or 'bspp'",
+			Value: "samu",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: fmt.Sprintf("output format (one of: %s)", strings.Join(summary.Formats(), ", ")),
+			Value: summary.FORMAT_ASCII,
+		},
+		cli.BoolFlag{
+			Name:  "censor",
+			Usage: "leave crews out, e.g. before sharing the summary outside of the Red Cross",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		day, err := parseDay(c.String("day"), time.Now().AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+		var format = c.String("format")
+		if _, ok := summary.RENDERERS[format]; !ok {
+			return fmt.Errorf("unknown summary format '%s' (expected one of: %s)", format, strings.Join(summary.Formats(), ", "))
+		}
+
+		ctx, cancel := commandContext()
+		defer cancel()
+		_, err = initClient(ctx)
+		if err != nil {
+			return err
+		}
+		kind, err := pegassClient.ActivityKind(c.String("kind"))
+		if err != nil {
+			return err
+		}
+
+		daySummary, err := pegassClient.SummarizeDayContext(ctx, day.Format(time.DateOnly), kind, c.Bool("censor"))
+		if err != nil {
+			return err
+		}
+		output, err := summary.Render(format, daySummary)
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	},
+}
@@ -0,0 +1,137 @@
+package summary
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Activity statuses, as named by Pegass.
+const (
+	STATUS_COMPLETE   = "Complète"
+	STATUS_INCOMPLETE = "Incomplète"
+	STATUS_CANCELLED  = "Annulée"
+)
+
+// REGULATION_ACTIVITY is the name of the dispatch activity, whose crew is described differently.
+const REGULATION_ACTIVITY = "REGULATION"
+
+// DaySummary is the state of the activities of a kind on a given day.
+type DaySummary struct {
+	Day      string            `json:"day"`
+	Kind     string            `json:"kind"`
+	Sections []ActivitySection `json:"sections"`
+}
+
+// ActivitySection groups the seances of an activity, e.g. a first aid team.
+type ActivitySection struct {
+	Activity string `json:"activity"`
+	// Structure is the local unit carrying out the activity, or the association for external activities.
+	Structure string         `json:"structure,omitempty"`
+	External  bool           `json:"external,omitempty"`
+	Seances   []SeanceStatus `json:"seances"`
+}
+
+// SeanceStatus describes a seance and, when its registrations were checked, its crew and lint findings.
+type SeanceStatus struct {
+	ID     string    `json:"id"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Status string    `json:"status"`
+	// Crew is nil when registrations were not checked, e.g. for external activities or censored summaries.
+	Crew *Crew `json:"crew,omitempty"`
+	// Findings are the issues found in the crew, most important first.
+	Findings []Finding `json:"findings,omitempty"`
+}
+
+// Crew counts the volunteers registered to a seance.
+type Crew struct {
+	Registered int      `json:"registered"`
+	Chief      *Contact `json:"chief,omitempty"`
+	// Dispatchers, RadioOperators and Trainees are only counted for dispatch seances.
+	Dispatchers    int `json:"dispatchers,omitempty"`
+	RadioOperators int `json:"radio_operators,omitempty"`
+	Trainees       int `json:"trainees,omitempty"`
+	// DispatcherAssociation is the association of the dispatchers, "CRF" for the Red Cross.
+	DispatcherAssociation string `json:"dispatcher_association,omitempty"`
+	// DispatcherEvaluation is set when a dispatcher trainer evaluates the dispatcher.
+	DispatcherEvaluation bool `json:"dispatcher_evaluation,omitempty"`
+}
+
+// Contact is how to reach a volunteer. Phone is "(Inconnu)" when Pegass does not know it.
+type Contact struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Phone     string `json:"phone"`
+}
+
+// Finding is an issue found in the crew of a seance by a lint rule.
+type Finding struct {
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// Count is the number of volunteers involved, if relevant.
+	Count int `json:"count,omitempty"`
+}
+
+// Codes of the findings of the default lint rules.
+const (
+	FINDING_MINORS                 = "minors"
+	FINDING_TOO_MANY_PSE1          = "too_many_pse1"
+	FINDING_NO_PSE2                = "no_pse2"
+	FINDING_FORMER_FIRST_RESPONDER = "former_first_responder"
+)
+
+// Severities of findings.
+const (
+	SEVERITY_INFO    = "info"
+	SEVERITY_WARNING = "warning"
+	SEVERITY_ERROR   = "error"
+)
+
+var SEVERITIES = []string{SEVERITY_INFO, SEVERITY_WARNING, SEVERITY_ERROR}
+
+// IsRegulation reports whether the section is the dispatch activity.
+func (s ActivitySection) IsRegulation() bool {
+	return s.Activity == REGULATION_ACTIVITY
+}
+
+// Renderer formats a summary for a given medium.
+type Renderer func(summary DaySummary) (string, error)
+
+// Output formats.
+const (
+	FORMAT_WHATSAPP = "whatsapp"
+	FORMAT_MARKDOWN = "markdown"
+	FORMAT_HTML     = "html"
+	FORMAT_ASCII    = "ascii"
+	FORMAT_JSON     = "json"
+)
+
+var RENDERERS = map[string]Renderer{
+	FORMAT_WHATSAPP: RenderWhatsApp,
+	FORMAT_MARKDOWN: RenderMarkdown,
+	FORMAT_HTML:     RenderHTML,
+	FORMAT_ASCII:    RenderASCII,
+	FORMAT_JSON:     RenderJSON,
+}
+
+// Formats lists the names of the renderers.
+func Formats() []string {
+	var formats []string
+	for format := range RENDERERS {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Render formats a summary with the renderer of the given name.
+func Render(format string, summary DaySummary) (string, error) {
+	renderer, ok := RENDERERS[format]
+	if !ok {
+		return "", fmt.Errorf("unknown summary format '%s' (expected one of: %s)", format, strings.Join(Formats(), ", "))
+	}
+	return renderer(summary)
+}
@@ -0,0 +1,165 @@
+package summary
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+	"unicode"
+)
+
+// crewDescription describes the crew of a seance in plain words, e.g. "4 inscrits, chef : Jean Dupont 0600000000".
+func crewDescription(section ActivitySection, seance SeanceStatus) string {
+	crew := seance.Crew
+	if crew == nil {
+		return ""
+	}
+
+	var parts = []string{fmt.Sprintf("%d inscrit(s)", crew.Registered)}
+	if section.IsRegulation() && crew.Registered > 0 {
+		if crew.DispatcherAssociation != "" {
+			parts = append(parts, "régulation "+crew.DispatcherAssociation)
+		}
+		parts = append(parts, fmt.Sprintf("%d ARS, %d OPR, %d stagiaire(s)", crew.Dispatchers, crew.RadioOperators, crew.Trainees))
+		if crew.DispatcherEvaluation {
+			parts = append(parts, "évaluation régulateur")
+		}
+	}
+	if crew.Chief != nil {
+		parts = append(parts, fmt.Sprintf("chef : %s %s %s", crew.Chief.FirstName, crew.Chief.LastName, crew.Chief.Phone))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sectionTitle(section ActivitySection) string {
+	if section.Structure == "" {
+		return section.Activity
+	}
+	return fmt.Sprintf("%s (%s)", section.Activity, section.Structure)
+}
+
+// RenderMarkdown formats a summary as a Markdown document, e.g. for emails or wikis.
+func RenderMarkdown(summary DaySummary) (string, error) {
+	var buffer strings.Builder
+	buffer.WriteString(fmt.Sprintf("# %s — %s\n", summary.Kind, summary.Day))
+	for _, section := range summary.Sections {
+		buffer.WriteString(fmt.Sprintf("\n## %s\n\n", sectionTitle(section)))
+		for _, seance := range section.Seances {
+			buffer.WriteString(fmt.Sprintf("- %s %s - %s **%s**", mapStatusToEmoji(seance.Status), seance.Start.Format("15:04"), seance.End.Format("15:04"), seance.Status))
+			if crew := crewDescription(section, seance); crew != "" {
+				buffer.WriteString(" — " + crew)
+			}
+			buffer.WriteString("\n")
+			for _, finding := range seance.Findings {
+				buffer.WriteString(fmt.Sprintf("  - %s%s\n", severityEmoji(finding.Severity), finding.Message))
+			}
+		}
+	}
+	return buffer.String(), nil
+}
+
+// RenderASCII formats a summary as plain text without emoji, e.g. for terminals or SMS.
+func RenderASCII(summary DaySummary) (string, error) {
+	var buffer strings.Builder
+	buffer.WriteString(fmt.Sprintf("%s - %s\n", summary.Kind, summary.Day))
+	for _, section := range summary.Sections {
+		buffer.WriteString(fmt.Sprintf("\n%s\n", sectionTitle(section)))
+		for _, seance := range section.Seances {
+			buffer.WriteString(fmt.Sprintf("  %-12s %s - %s", asciiStatus(seance.Status), seance.Start.Format("15:04"), seance.End.Format("15:04")))
+			if crew := crewDescription(section, seance); crew != "" {
+				buffer.WriteString("  " + crew)
+			}
+			buffer.WriteString("\n")
+			for _, finding := range seance.Findings {
+				buffer.WriteString(fmt.Sprintf("    %s %s\n", asciiSeverity(finding.Severity), finding.Message))
+			}
+		}
+	}
+	return toASCII(buffer.String()), nil
+}
+
+func asciiSeverity(severity string) string {
+	switch severity {
+	case SEVERITY_INFO:
+		return "i"
+	case SEVERITY_ERROR:
+		return "!!"
+	default:
+		return "!"
+	}
+}
+
+var frenchAccents = strings.NewReplacer(
+	"à", "a", "â", "a", "ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e", "î", "i", "ï", "i", "ô", "o", "ù", "u", "û", "u",
+	"À", "A", "Â", "A", "Ç", "C", "É", "E", "È", "E", "Ê", "E", "Î", "I", "Ô", "O", "Ù", "U", "Û", "U",
+)
+
+// toASCII removes the accents of French letters, then drops any character left outside of ASCII.
+func toASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			return -1
+		}
+		return r
+	}, frenchAccents.Replace(s))
+}
+
+func asciiStatus(status string) string {
+	switch status {
+	case STATUS_COMPLETE:
+		return "[COMPLETE]"
+	case STATUS_INCOMPLETE:
+		return "[INCOMPLETE]"
+	case STATUS_CANCELLED:
+		return "[CANCELLED]"
+	}
+	return "[" + strings.ToUpper(status) + "]"
+}
+
+var htmlTemplate = template.Must(template.New("summary").Funcs(template.FuncMap{
+	"title":    sectionTitle,
+	"crew":     crewDescription,
+	"status":   mapStatusToEmoji,
+	"severity": severityEmoji,
+}).Parse(`<h1>{{.Kind}} — {{.Day}}</h1>
+{{- range $section := .Sections}}
+<h2>{{title $section}}</h2>
+<ul>
+{{- range $seance := $section.Seances}}
+  <li>{{status $seance.Status}} {{$seance.Start.Format "15:04"}} - {{$seance.End.Format "15:04"}} <strong>{{$seance.Status}}</strong>
+    {{- with crew $section $seance}} — {{.}}{{end}}
+    {{- if $seance.Findings}}
+    <ul>
+    {{- range $seance.Findings}}
+      <li>{{severity .Severity}}{{.Message}}</li>
+    {{- end}}
+    </ul>
+    {{- end}}
+  </li>
+{{- end}}
+</ul>
+{{- end}}
+`))
+
+// RenderHTML formats a summary as an HTML fragment, e.g. for emails.
+func RenderHTML(summary DaySummary) (string, error) {
+	var buffer bytes.Buffer
+	err := htmlTemplate.Execute(&buffer, summary)
+	if err != nil {
+		return "", fmt.Errorf("failed to render summary as HTML: %w", err)
+	}
+	return buffer.String(), nil
+}
+
+// RenderJSON formats a summary as JSON, for scripts.
+func RenderJSON(summary DaySummary) (string, error) {
+	if summary.Sections == nil {
+		summary.Sections = []ActivitySection{}
+	}
+	encoded, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to render summary as JSON: %w", err)
+	}
+	return string(encoded) + "\n", nil
+}
@@ -0,0 +1,87 @@
+package summary
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RenderWhatsApp formats a summary as the WhatsApp messages sent by the bot.
+func RenderWhatsApp(summary DaySummary) (string, error) {
+	var buffer strings.Builder
+	for _, section := range summary.Sections {
+		buffer.WriteString(fmt.Sprintf("\n%s", section.Activity))
+		if section.Structure != "" {
+			buffer.WriteString(fmt.Sprintf(" [%s]", section.Structure))
+		}
+		buffer.WriteString("\n")
+
+		for _, seance := range section.Seances {
+			buffer.WriteString(fmt.Sprintf("\t%s — %s - %s %s\n", mapStatusToEmoji(seance.Status), seance.Start.Format("15:04"), seance.End.Format("15:04"), whatsAppCrew(section, seance)))
+		}
+	}
+	return buffer.String(), nil
+}
+
+func whatsAppCrew(section ActivitySection, seance SeanceStatus) string {
+	crew := seance.Crew
+	if crew == nil {
+		return ""
+	}
+	if crew.Registered == 0 {
+		return "[0 PAX]"
+	}
+
+	var buffer strings.Builder
+	if section.IsRegulation() {
+		buffer.WriteString(fmt.Sprintf("[%d PAX]", crew.Registered))
+		if crew.DispatcherAssociation != "" {
+			buffer.WriteString(fmt.Sprintf("[%s]", crew.DispatcherAssociation))
+		}
+		buffer.WriteString(fmt.Sprintf("\n\t\t%d ARS, %d OPR, %d Stagiaire", crew.Dispatchers, crew.RadioOperators, crew.Trainees))
+		if crew.DispatcherEvaluation {
+			buffer.WriteString("\n\t\tℹ️Evaluation régulateur")
+		}
+	} else {
+		buffer.WriteString(fmt.Sprintf("[%d PAX]", crew.Registered))
+	}
+
+	// Minors are pointed out before the chief's contact, other findings after it
+	for _, finding := range seance.Findings {
+		if finding.Code == FINDING_MINORS {
+			buffer.WriteString("\n\t\t" + severityEmoji(finding.Severity) + finding.Message)
+		}
+	}
+	if crew.Chief != nil {
+		buffer.WriteString(fmt.Sprintf("\n\t\t📞 %s %s %s", crew.Chief.FirstName, crew.Chief.LastName, crew.Chief.Phone))
+	}
+	for _, finding := range seance.Findings {
+		if finding.Code != FINDING_MINORS {
+			buffer.WriteString("\n\t\t" + severityEmoji(finding.Severity) + finding.Message)
+		}
+	}
+	return buffer.String()
+}
+
+func mapStatusToEmoji(status string) string {
+	switch status {
+	case STATUS_COMPLETE:
+		return "✅ "
+	case STATUS_INCOMPLETE:
+		return "❌ "
+	case STATUS_CANCELLED:
+		return "🟡"
+	default:
+		return "?"
+	}
+}
+
+func severityEmoji(severity string) string {
+	switch severity {
+	case SEVERITY_INFO:
+		return "ℹ️ "
+	case SEVERITY_ERROR:
+		return "🚨 "
+	default:
+		return "⚠️ "
+	}
+}
@@ -0,0 +1,254 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"github.com/fabien-chebel/pegass-cli/logging"
+	"github.com/fabien-chebel/pegass-cli/metrics"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	// MaxRetries is the number of retries of an idempotent request failing with a network error or a 5xx/429 status.
+	MaxRetries int
+	// BaseDelay and MaxDelay bound the jittered exponential backoff between retries.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxRetryAfter caps how long a Retry-After header may delay a retry. Longer delays are not retried.
+	MaxRetryAfter time.Duration
+	// AttemptTimeout bounds each attempt, until its response body is closed. Zero disables it.
+	AttemptTimeout time.Duration
+	// RequestsPerSecond limits the rate of requests sent to each host. Zero disables rate limiting.
+	RequestsPerSecond float64
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MaxRetries:        3,
+		BaseDelay:         500 * time.Millisecond,
+		MaxDelay:          10 * time.Second,
+		MaxRetryAfter:     30 * time.Second,
+		AttemptTimeout:    60 * time.Second,
+		RequestsPerSecond: 10,
+	}
+}
+
+// Budget is how long a request may take in the worst case, every attempt timing out and every retry waiting
+// as long as allowed, plus some leeway for rate limiting. Timeouts set on the http.Client, which cover every
+// attempt, must not be shorter. It is zero, meaning unbounded, when attempts are not bounded.
+func (o Options) Budget() time.Duration {
+	if o.AttemptTimeout <= 0 {
+		return 0
+	}
+	attempts := time.Duration(o.MaxRetries + 1)
+	return attempts*(o.AttemptTimeout+time.Second) + time.Duration(o.MaxRetries)*max(o.MaxDelay, o.MaxRetryAfter)
+}
+
+// NewBaseTransport returns a http.Transport with sane connection timeouts. Request timeouts are set on the
+// RetryTransport, on the http.Client, or through the request context.
+func NewBaseTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   10,
+	}
+}
+
+// RetryTransport is a http.RoundTripper retrying idempotent requests with a jittered exponential backoff,
+// honouring Retry-After headers, and limiting the rate of requests sent to each host.
+type RetryTransport struct {
+	next    http.RoundTripper
+	options Options
+	mutex   sync.Mutex
+	nextAt  map[string]time.Time
+}
+
+func New(next http.RoundTripper, options Options) *RetryTransport {
+	if next == nil {
+		next = NewBaseTransport()
+	}
+	return &RetryTransport{
+		next:    next,
+		options: options,
+		nextAt:  make(map[string]time.Time),
+	}
+}
+
+func (t *RetryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	retryable := isIdempotent(request.Method) && (request.Body == nil || request.GetBody != nil)
+
+	for attempt := 0; ; attempt++ {
+		err := t.wait(request.Context(), request.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		// Attempts are sent as clones, leaving the caller's request untouched
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if t.options.AttemptTimeout > 0 {
+			ctx, cancel = context.WithTimeout(request.Context(), t.options.AttemptTimeout)
+		} else {
+			ctx, cancel = context.WithCancel(request.Context())
+		}
+		attemptRequest := request.Clone(ctx)
+		if attempt > 0 && request.GetBody != nil {
+			attemptRequest.Body, err = request.GetBody()
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+		}
+
+		response, err := t.next.RoundTrip(attemptRequest)
+		if !retryable || attempt >= t.options.MaxRetries || !shouldRetry(request.Context(), response, err) {
+			return withCancel(response, cancel), err
+		}
+
+		delay := t.backoff(attempt)
+		if response != nil {
+			if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+				if retryAfter > t.options.MaxRetryAfter {
+					return withCancel(response, cancel), nil
+				}
+				delay = retryAfter
+			}
+			// Drain the body so that the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+			response.Body.Close()
+		}
+		cancel()
+
+		fields := log.Fields{
+			"method":   request.Method,
+			"host":     request.URL.Host,
+			"endpoint": metrics.NormalizeEndpoint(request.URL.Path),
+			"attempt":  attempt + 1,
+			"delay":    delay,
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		} else {
+			fields["statusCode"] = response.StatusCode
+		}
+		logging.FromContext(request.Context()).WithFields(fields).Warnf("request to %s failed, retrying", request.URL.Host)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-request.Context().Done():
+			timer.Stop()
+			return nil, request.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// cancelOnClose releases the context of an attempt once its response body is read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// withCancel ties cancel to the body of response, or calls it right away when there is no response.
+func withCancel(response *http.Response, cancel context.CancelFunc) *http.Response {
+	if response == nil || response.Body == nil {
+		cancel()
+		return response
+	}
+	response.Body = &cancelOnClose{ReadCloser: response.Body, cancel: cancel}
+	return response
+}
+
+// wait blocks until a request may be sent to the given host without exceeding the rate limit.
+func (t *RetryTransport) wait(ctx context.Context, host string) error {
+	if t.options.RequestsPerSecond <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / t.options.RequestsPerSecond)
+
+	t.mutex.Lock()
+	now := time.Now()
+	slot := t.nextAt[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	t.nextAt[host] = slot.Add(interval)
+	t.mutex.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns a random delay between zero and the exponential backoff for the given attempt ("full jitter").
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	ceiling := t.options.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > t.options.MaxDelay {
+		ceiling = t.options.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func shouldRetry(ctx context.Context, response *http.Response, err error) bool {
+	if err != nil {
+		// Do not retry when the caller gave up, but do when only the attempt timed out
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled)
+	}
+	return response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
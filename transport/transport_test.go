@@ -0,0 +1,159 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testOptions retry quickly and without rate limiting.
+func testOptions() Options {
+	return Options{
+		MaxRetries:     2,
+		BaseDelay:      time.Millisecond,
+		MaxDelay:       5 * time.Millisecond,
+		MaxRetryAfter:  2 * time.Second,
+		AttemptTimeout: time.Second,
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		statuses   []int
+		retryAfter string
+		attempts   int32
+		status     int
+	}{
+		{name: "success", method: http.MethodGet, statuses: []int{200}, attempts: 1, status: 200},
+		{name: "server error then success", method: http.MethodGet, statuses: []int{503, 500, 200}, attempts: 3, status: 200},
+		{name: "too many requests", method: http.MethodGet, statuses: []int{429, 200}, retryAfter: "0", attempts: 2, status: 200},
+		{name: "retries exhausted", method: http.MethodGet, statuses: []int{502, 502, 502, 200}, attempts: 3, status: 502},
+		{name: "client error", method: http.MethodGet, statuses: []int{404, 200}, attempts: 1, status: 404},
+		{name: "retry after too long", method: http.MethodGet, statuses: []int{503, 200}, retryAfter: "3600", attempts: 1, status: 503},
+		{name: "post is not retried", method: http.MethodPost, statuses: []int{503, 200}, attempts: 1, status: 503},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := attempts.Add(1)
+				if test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.WriteHeader(test.statuses[attempt-1])
+				io.WriteString(w, r.Method)
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: New(nil, testOptions())}
+			request, err := http.NewRequest(test.method, server.URL, strings.NewReader(""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := client.Do(request)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			body, err := io.ReadAll(response.Body)
+			response.Body.Close()
+			if err != nil {
+				t.Fatalf("failed to read body: %s", err)
+			}
+
+			if response.StatusCode != test.status {
+				t.Errorf("expected status %d, got %d", test.status, response.StatusCode)
+			}
+			if string(body) != test.method {
+				t.Errorf("expected body '%s', got '%s'", test.method, body)
+			}
+			if attempts.Load() != test.attempts {
+				t.Errorf("expected %d attempts, got %d", test.attempts, attempts.Load())
+			}
+		})
+	}
+}
+
+func TestRetryTransportAttemptTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	options := testOptions()
+	options.AttemptTimeout = 50 * time.Millisecond
+	client := &http.Client{Transport: New(nil, options)}
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	response.Body.Close()
+	if attempts.Load() != 2 {
+		t.Errorf("expected the timed out attempt to be retried, got %d attempts", attempts.Load())
+	}
+}
+
+func TestRetryTransportCancelled(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	options := testOptions()
+	options.BaseDelay, options.MaxDelay = time.Hour, time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = (&http.Client{Transport: New(nil, options)}).Do(request)
+	if err == nil || ctx.Err() == nil {
+		t.Fatalf("expected the request to be cancelled while waiting, got %v", err)
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("expected a single attempt, got %d", attempts.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		ok       bool
+		expected time.Duration
+	}{
+		{value: "", ok: false},
+		{value: "0", ok: true, expected: 0},
+		{value: "120", ok: true, expected: 2 * time.Minute},
+		{value: "-1", ok: false},
+		{value: "soon", ok: false},
+		{value: "Wed, 21 Oct 2015 07:28:00 GMT", ok: true, expected: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			delay, ok := parseRetryAfter(test.value)
+			if ok != test.ok || delay != test.expected {
+				t.Errorf("expected (%s, %t), got (%s, %t)", test.expected, test.ok, delay, ok)
+			}
+		})
+	}
+
+	future := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	delay, ok := parseRetryAfter(future)
+	if !ok || delay <= 30*time.Second || delay > time.Minute {
+		t.Errorf("expected a delay of about a minute for '%s', got %s", future, delay)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fabien-chebel/pegass-cli/vault"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+	"gopkg.in/urfave/cli.v1"
+	"io"
+	"os"
+	"strings"
+)
+
+const VAULT_PASSPHRASE_ENV = "PEGASS_VAULT_PASSPHRASE"
+
+var vaultPath string
+var vaultKeyFile string
+
+// readVaultPassphrase resolves the master passphrase, in order of precedence, from the key file,
+// from the PEGASS_VAULT_PASSPHRASE environment variable or from an interactive prompt.
+func readVaultPassphrase(prompt string) ([]byte, error) {
+	if vaultKeyFile != "" {
+		content, err := os.ReadFile(vaultKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault key file: %w", err)
+		}
+		return bytes.TrimSpace(content), nil
+	}
+
+	if passphrase := os.Getenv(VAULT_PASSPHRASE_ENV); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	return promptSecret(prompt)
+}
+
+func promptSecret(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("no terminal available to prompt for '%s'; use a key file or the %s environment variable", prompt, VAULT_PASSPHRASE_ENV)
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret from terminal: %w", err)
+	}
+	return secret, nil
+}
+
+func promptLine(prompt string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read from standard input: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func openVault() (*vault.Vault, error) {
+	passphrase, err := readVaultPassphrase("Vault passphrase")
+	if err != nil {
+		return nil, err
+	}
+	return vault.Open(vaultPath, passphrase)
+}
+
+// readLegacyConfig parses 'config.json' as raw values, so that it can be written back unchanged but for
+// secrets. It is nil when there is no 'config.json'.
+func readLegacyConfig() (map[string]json.RawMessage, error) {
+	fileContent, err := os.ReadFile(profilePath("config.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var legacy map[string]json.RawMessage
+	err = json.Unmarshal(fileContent, &legacy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse application configuration file: %w", err)
+	}
+	return legacy, nil
+}
+
+// plaintextSecrets lists the secrets of the vault still found in 'config.json'.
+func plaintextSecrets(legacy map[string]json.RawMessage) []string {
+	var keys []string
+	for _, key := range vault.Keys {
+		if _, ok := legacy[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// checkNoPlaintextSecrets refuses to run while 'config.json' holds secrets, which belong to the vault.
+func checkNoPlaintextSecrets() error {
+	legacy, err := readLegacyConfig()
+	if err != nil {
+		return err
+	}
+	if keys := plaintextSecrets(legacy); len(keys) > 0 {
+		return fmt.Errorf("'%s' holds plaintext secrets (%s): remove them once stored with 'pegass-cli vault set', or import them into a new vault with 'pegass-cli vault init --remove-from-config'", profilePath("config.json"), strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// importLegacySecrets moves plaintext secrets found in 'config.json' into the vault, removing them from
+// 'config.json' when removeFromConfig is set.
+func importLegacySecrets(v *vault.Vault, removeFromConfig bool) error {
+	legacy, err := readLegacyConfig()
+	if err != nil {
+		return err
+	}
+	keys := plaintextSecrets(legacy)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var values = make(map[string]string)
+	for _, key := range keys {
+		var value string
+		if json.Unmarshal(legacy[key], &value) == nil && value != "" {
+			values[key] = value
+		}
+	}
+	err = v.SetAll(values)
+	if err != nil {
+		return fmt.Errorf("failed to import secrets from 'config.json': %w", err)
+	}
+	for _, key := range keys {
+		if _, ok := values[key]; ok {
+			log.Infof("Imported '%s' from 'config.json' into the vault", key)
+		}
+	}
+
+	if !removeFromConfig {
+		log.Warnf("'config.json' still holds plaintext secrets (%s): remove them before using the vault", strings.Join(keys, ", "))
+		return nil
+	}
+	for _, key := range keys {
+		delete(legacy, key)
+	}
+	content, err := json.MarshalIndent(legacy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize application configuration: %w", err)
+	}
+	err = os.WriteFile(profilePath("config.json"), append(content, '\n'), 0600)
+	if err != nil {
+		return fmt.Errorf("failed to remove secrets from 'config.json': %w", err)
+	}
+	log.Infof("Removed %s from 'config.json'", strings.Join(keys, ", "))
+	return nil
+}
+
+// readSecret prompts for the value of a vault key, hiding it unless it is the username, or reads its first line
+// from standard input when it is not a terminal.
+func readSecret(key string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("failed to read '%s' from standard input: %w", key, err)
+		}
+		return strings.TrimSpace(line), nil
+	}
+	if key == vault.KeyUsername {
+		return promptLine("Value for '" + key + "'")
+	}
+	secret, err := promptSecret("Value for '" + key + "'")
+	return string(secret), err
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no when there is no terminal.
+func confirm(question string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	answer, err := promptLine(question + " [y/N]")
+	return err == nil && strings.EqualFold(answer, "y")
+}
+
+var vaultCommand = cli.Command{
+	Name:  "vault",
+	Usage: "Manage the encrypted vault holding Pegass credentials and session",
+	Subcommands: []cli.Command{
+		{
+			Name:  "init",
+			Usage: "Create a new vault, importing plaintext secrets from 'config.json' if any",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "remove-from-config",
+					Usage: "remove imported secrets from 'config.json' without asking",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				passphrase, err := readVaultPassphrase("New vault passphrase")
+				if err != nil {
+					return err
+				}
+				if vaultKeyFile == "" && os.Getenv(VAULT_PASSPHRASE_ENV) == "" {
+					confirmation, err := promptSecret("Confirm vault passphrase")
+					if err != nil {
+						return err
+					}
+					if !bytes.Equal(passphrase, confirmation) {
+						return errors.New("passphrases do not match")
+					}
+				}
+
+				v, err := vault.Create(vaultPath, passphrase)
+				if err != nil {
+					return err
+				}
+				legacy, err := readLegacyConfig()
+				if err != nil {
+					return err
+				}
+				removeFromConfig := c.Bool("remove-from-config")
+				if keys := plaintextSecrets(legacy); len(keys) > 0 && !removeFromConfig {
+					removeFromConfig = confirm(fmt.Sprintf("Remove %s from 'config.json' once imported?", strings.Join(keys, ", ")))
+				}
+				err = importLegacySecrets(v, removeFromConfig)
+				if err != nil {
+					return err
+				}
+				log.Infof("Vault created at '%s'", v.Path())
+				return nil
+			},
+		},
+		{
+			Name:      "set",
+			Usage:     fmt.Sprintf("Store a secret in the vault (one of: %s), prompting for it or reading it from standard input", strings.Join(vault.Keys, ", ")),
+			ArgsUsage: "<key>",
+			Action: func(c *cli.Context) error {
+				key := c.Args().Get(0)
+				if key == "" {
+					return fmt.Errorf("missing vault key (one of: %s)", strings.Join(vault.Keys, ", "))
+				}
+				if c.NArg() > 1 {
+					// Arguments end up in the shell history and in the process list
+					return errors.New("secrets are not accepted as arguments: type them when prompted, or pipe them on standard input")
+				}
+
+				v, err := openVault()
+				if err != nil {
+					return err
+				}
+
+				value, err := readSecret(key)
+				if err != nil {
+					return err
+				}
+				if value == "" {
+					return fmt.Errorf("no value given for '%s'", key)
+				}
+
+				err = v.Set(key, value)
+				if err != nil {
+					return err
+				}
+				log.Infof("Updated '%s' in vault", key)
+				return nil
+			},
+		},
+		{
+			Name:  "rotate",
+			Usage: "Re-encrypt the vault with a new passphrase",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "new-key-file",
+					Usage: "read the new passphrase from this file instead of prompting for it",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				v, err := openVault()
+				if err != nil {
+					return err
+				}
+
+				var newPassphrase []byte
+				if newKeyFile := c.String("new-key-file"); newKeyFile != "" {
+					content, err := os.ReadFile(newKeyFile)
+					if err != nil {
+						return fmt.Errorf("failed to read new vault key file: %w", err)
+					}
+					newPassphrase = bytes.TrimSpace(content)
+				} else {
+					newPassphrase, err = promptSecret("New vault passphrase")
+					if err != nil {
+						return err
+					}
+					confirmation, err := promptSecret("Confirm new vault passphrase")
+					if err != nil {
+						return err
+					}
+					if !bytes.Equal(newPassphrase, confirmation) {
+						return errors.New("passphrases do not match")
+					}
+				}
+
+				err = v.Rotate(newPassphrase)
+				if err != nil {
+					return err
+				}
+				log.Info("Vault passphrase rotated")
+				return nil
+			},
+		},
+	},
+}
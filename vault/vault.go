@@ -0,0 +1,262 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"os"
+	"time"
+)
+
+const (
+	vaultVersion = 1
+	keyLength    = 32
+	saltLength   = 16
+)
+
+const (
+	KeyUsername      = "username"
+	KeyPassword      = "password"
+	KeyTotpSecretKey = "totp_secret_key"
+)
+
+// Keys lists the secrets that can be set through Vault.Set.
+var Keys = []string{KeyUsername, KeyPassword, KeyTotpSecretKey}
+
+var (
+	ErrInvalidPassphrase = errors.New("invalid vault passphrase or corrupted vault")
+	ErrVaultExists       = errors.New("vault already exists")
+)
+
+// Secrets holds every sensitive value needed to talk to Pegass.
+type Secrets struct {
+	Username      string   `json:"username"`
+	Password      string   `json:"password"`
+	TotpSecretKey string   `json:"totp_secret_key"`
+	PegassSession *Session `json:"pegass_session,omitempty"`
+}
+
+// Session is the state obtained after a successful login: the whole Pegass cookie set, the Okta
+// session cookies, and some metadata used to decide whether the session can be reused.
+type Session struct {
+	Nivol         string    `json:"nivol"`
+	IssuedAt      time.Time `json:"issued_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	PegassCookies []Cookie  `json:"pegass_cookies"`
+	OktaCookies   []Cookie  `json:"okta_cookies"`
+	OktaExpiresAt time.Time `json:"okta_expires_at"`
+}
+
+type Cookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires"`
+	Secure   bool      `json:"secure"`
+	HttpOnly bool      `json:"http_only"`
+}
+
+// Expired reports whether the Pegass cookies are known to have expired. A zero ExpiresAt means
+// that no expiry was observed, in which case the session must be checked against Pegass.
+func (s *Session) Expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && now.After(s.ExpiresAt)
+}
+
+// OktaExpired reports whether the Okta session cookies are known to have expired.
+func (s *Session) OktaExpired(now time.Time) bool {
+	return !s.OktaExpiresAt.IsZero() && now.After(s.OktaExpiresAt)
+}
+
+// envelope is the on-disk representation of an encrypted vault.
+type envelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type Vault struct {
+	path       string
+	passphrase []byte
+	secrets    Secrets
+}
+
+// Create initializes a new, empty vault at the given path. It fails if a file already exists there.
+func Create(path string, passphrase []byte) (*Vault, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrVaultExists, path)
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("vault passphrase must not be empty")
+	}
+
+	v := &Vault{
+		path:       path,
+		passphrase: passphrase,
+	}
+	return v, v.Save()
+}
+
+// Open decrypts the vault stored at the given path.
+func Open(path string, passphrase []byte) (*Vault, error) {
+	fileContent, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault file: %w", err)
+	}
+
+	var env envelope
+	err = json.Unmarshal(fileContent, &env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vault file: %w", err)
+	}
+	if env.Version != vaultVersion || env.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported vault format (version %d, kdf '%s')", env.Version, env.KDF)
+	}
+
+	aead, err := newAEAD(passphrase, env.Salt, env.N, env.R, env.P)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrInvalidPassphrase
+	}
+
+	v := &Vault{
+		path:       path,
+		passphrase: passphrase,
+	}
+	err = json.Unmarshal(plaintext, &v.secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize vault content: %w", err)
+	}
+	return v, nil
+}
+
+func (v *Vault) Path() string {
+	return v.path
+}
+
+func (v *Vault) Secrets() Secrets {
+	return v.secrets
+}
+
+// Set updates a single secret, identified by one of Keys, and persists the vault.
+func (v *Vault) Set(key string, value string) error {
+	return v.SetAll(map[string]string{key: value})
+}
+
+// SetAll updates several secrets, identified by Keys, and persists the vault once. Nothing is changed when a
+// key is unknown.
+func (v *Vault) SetAll(values map[string]string) error {
+	var secrets = v.secrets
+	for key, value := range values {
+		switch key {
+		case KeyUsername:
+			secrets.Username = value
+		case KeyPassword:
+			secrets.Password = value
+		case KeyTotpSecretKey:
+			secrets.TotpSecretKey = value
+		default:
+			return fmt.Errorf("unknown vault key '%s'", key)
+		}
+	}
+
+	previous := v.secrets
+	v.secrets = secrets
+	err := v.Save()
+	if err != nil {
+		v.secrets = previous
+	}
+	return err
+}
+
+// Session returns the Pegass session stored in the vault, if any.
+func (v *Vault) Session() *Session {
+	return v.secrets.PegassSession
+}
+
+// SetSession stores the Pegass session in the vault and persists it. A nil session clears it.
+func (v *Vault) SetSession(session *Session) error {
+	v.secrets.PegassSession = session
+	return v.Save()
+}
+
+// Rotate re-encrypts the vault with a new passphrase, using a fresh salt.
+func (v *Vault) Rotate(newPassphrase []byte) error {
+	if len(newPassphrase) == 0 {
+		return errors.New("vault passphrase must not be empty")
+	}
+	v.passphrase = newPassphrase
+	return v.Save()
+}
+
+// Save encrypts the vault content and atomically writes it to disk.
+func (v *Vault) Save() error {
+	plaintext, err := json.Marshal(v.secrets)
+	if err != nil {
+		return fmt.Errorf("failed to serialize vault content: %w", err)
+	}
+
+	env := envelope{
+		Version: vaultVersion,
+		KDF:     "scrypt",
+		N:       1 << 15,
+		R:       8,
+		P:       1,
+		Salt:    make([]byte, saltLength),
+	}
+	_, err = rand.Read(env.Salt)
+	if err != nil {
+		return fmt.Errorf("failed to generate vault salt: %w", err)
+	}
+
+	aead, err := newAEAD(v.passphrase, env.Salt, env.N, env.R, env.P)
+	if err != nil {
+		return err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	_, err = rand.Read(env.Nonce)
+	if err != nil {
+		return fmt.Errorf("failed to generate vault nonce: %w", err)
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plaintext, nil)
+
+	fileContent, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize vault file: %w", err)
+	}
+
+	tmpPath := v.path + ".tmp"
+	err = os.WriteFile(tmpPath, fileContent, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	err = os.Rename(tmpPath, v.path)
+	if err != nil {
+		return fmt.Errorf("failed to replace vault file: %w", err)
+	}
+	return nil
+}
+
+func newAEAD(passphrase []byte, salt []byte, n int, r int, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, n, r, p, keyLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive vault key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vault cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
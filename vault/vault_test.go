@@ -0,0 +1,108 @@
+package vault
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+
+	v, err := Create(path, []byte("first passphrase"))
+	if err != nil {
+		t.Fatalf("failed to create vault: %s", err)
+	}
+	err = v.SetAll(map[string]string{KeyUsername: "jdoe", KeyPassword: "hunter2"})
+	if err != nil {
+		t.Fatalf("failed to set secrets: %s", err)
+	}
+	err = v.SetSession(&Session{Nivol: "01100009672H"})
+	if err != nil {
+		t.Fatalf("failed to set session: %s", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"jdoe", "hunter2", "01100009672H"} {
+		if strings.Contains(string(content), secret) {
+			t.Errorf("expected '%s' to be encrypted", secret)
+		}
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the vault to only be readable by its owner, got %v (%v)", info.Mode(), err)
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+		rotateTo   string
+		err        error
+	}{
+		{name: "wrong passphrase", passphrase: "second passphrase", err: ErrInvalidPassphrase},
+		{name: "right passphrase", passphrase: "first passphrase"},
+		{name: "rotation", passphrase: "first passphrase", rotateTo: "second passphrase"},
+		{name: "former passphrase", passphrase: "first passphrase", err: ErrInvalidPassphrase},
+		{name: "new passphrase", passphrase: "second passphrase"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opened, err := Open(path, []byte(test.passphrase))
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected error %v, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to open vault: %s", err)
+			}
+
+			secrets := opened.Secrets()
+			if secrets.Username != "jdoe" || secrets.Password != "hunter2" || secrets.TotpSecretKey != "" {
+				t.Errorf("unexpected secrets %+v", secrets)
+			}
+			if session := opened.Session(); session == nil || session.Nivol != "01100009672H" {
+				t.Errorf("unexpected session %+v", session)
+			}
+
+			if test.rotateTo != "" {
+				err = opened.Rotate([]byte(test.rotateTo))
+				if err != nil {
+					t.Fatalf("failed to rotate passphrase: %s", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	_, err := Create(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("failed to create vault: %s", err)
+	}
+	_, err = Create(path, []byte("passphrase"))
+	if !errors.Is(err, ErrVaultExists) {
+		t.Errorf("expected error %v, got %v", ErrVaultExists, err)
+	}
+}
+
+func TestSetAllUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	v, err := Create(path, []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("failed to create vault: %s", err)
+	}
+	err = v.SetAll(map[string]string{KeyUsername: "jdoe", "nivol": "01100009672H"})
+	if err == nil {
+		t.Fatal("expected unknown key to be rejected")
+	}
+	if v.Secrets().Username != "" {
+		t.Errorf("expected no secret to be changed, got %+v", v.Secrets())
+	}
+}
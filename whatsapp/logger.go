@@ -0,0 +1,20 @@
+package whatsapp
+
+import (
+	log "github.com/sirupsen/logrus"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// logger sends whatsmeow logs through logrus, so that they share the format and redaction of the other logs.
+type logger struct {
+	*log.Entry
+	module string
+}
+
+func newLogger(module string) waLog.Logger {
+	return logger{Entry: log.WithField("module", module), module: module}
+}
+
+func (l logger) Sub(module string) waLog.Logger {
+	return newLogger(l.module + "/" + module)
+}
@@ -3,6 +3,7 @@ package whatsapp
 import (
 	"context"
 	"fmt"
+	"github.com/fabien-chebel/pegass-cli/metrics"
 	"github.com/mdp/qrterminal/v3"
 	log "github.com/sirupsen/logrus"
 	"go.mau.fi/whatsmeow"
@@ -10,10 +11,10 @@ import (
 	"go.mau.fi/whatsmeow/store/sqlstore"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
-	waLog "go.mau.fi/whatsmeow/util/log"
 	"google.golang.org/protobuf/proto"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -21,10 +22,17 @@ import (
 type WhatsAppClient struct {
 	client            *whatsmeow.Client
 	onMessageReceived MessageCallback
+	dbPath            string
+	// hasConnected tells reconnections apart from the first connection.
+	hasConnected atomic.Bool
+	metrics      *metrics.Metrics
 }
 
-func NewClient() WhatsAppClient {
-	return WhatsAppClient{}
+// NewClient creates a client whose device store is kept in the given SQLite database file.
+func NewClient(dbPath string) WhatsAppClient {
+	return WhatsAppClient{
+		dbPath: dbPath,
+	}
 }
 
 func (w *WhatsAppClient) RegisterDevice() error {
@@ -55,12 +63,17 @@ func (w *WhatsAppClient) RegisterDevice() error {
 }
 
 func (w *WhatsAppClient) SendMessage(message string, groupId types.JID) error {
+	return w.SendMessageContext(context.Background(), message, groupId)
+}
+
+// SendMessageContext sends a text message, giving up once ctx is done.
+func (w *WhatsAppClient) SendMessageContext(ctx context.Context, message string, groupId types.JID) error {
 	err := w.initAndConnectIfNecessary()
 	if err != nil {
 		return err
 	}
 
-	_, err = w.client.SendMessage(context.Background(), groupId, &waProto.Message{Conversation: proto.String(message)})
+	_, err = w.client.SendMessage(ctx, groupId, &waProto.Message{Conversation: proto.String(message)})
 	if err != nil {
 		return err
 	}
@@ -68,13 +81,9 @@ func (w *WhatsAppClient) SendMessage(message string, groupId types.JID) error {
 }
 
 func (w *WhatsAppClient) initClient() error {
-	var minLogLevel = "INFO"
-	if os.Getenv("VERBOSE") != "" {
-		minLogLevel = "DEBUG"
-	}
-	dbLog := waLog.Stdout("Database", minLogLevel, true)
+	dbLog := newLogger("Database")
 	ctx := context.Background()
-	container, err := sqlstore.New(ctx, "sqlite", "file:pegass.db?_pragma=foreign_keys(1)", dbLog)
+	container, err := sqlstore.New(ctx, "sqlite", fmt.Sprintf("file:%s?_pragma=foreign_keys(1)", w.dbPath), dbLog)
 	if err != nil {
 		return err
 	}
@@ -82,8 +91,7 @@ func (w *WhatsAppClient) initClient() error {
 	if err != nil {
 		return err
 	}
-	clientLog := waLog.Stdout("Client", minLogLevel, true)
-	w.client = whatsmeow.NewClient(device, clientLog)
+	w.client = whatsmeow.NewClient(device, newLogger("Client"))
 	return nil
 }
 
@@ -121,18 +129,28 @@ func (w *WhatsAppClient) PrintGroupList() error {
 }
 
 func (w *WhatsAppClient) StartBot() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return w.StartBotContext(ctx)
+}
+
+// StartBotContext listens to incoming messages until ctx is done.
+func (w *WhatsAppClient) StartBotContext(ctx context.Context) error {
 	err := w.initAndConnectIfNecessary()
 	if err != nil {
 		return err
 	}
 
 	w.client.AddEventHandler(w.eventHandler)
-
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	<-c
+	if w.client.IsConnected() {
+		// The Connected event was sent before the handler was registered
+		w.hasConnected.Store(true)
+		w.setConnected(true)
+	}
+	<-ctx.Done()
 
 	w.client.Disconnect()
+	w.setConnected(false)
 	return nil
 }
 
@@ -140,6 +158,22 @@ func (w *WhatsAppClient) SetMessageCallback(callback MessageCallback) {
 	w.onMessageReceived = callback
 }
 
+// SetMetrics reports the state of the connection to WhatsApp. No metrics are reported otherwise.
+func (w *WhatsAppClient) SetMetrics(m *metrics.Metrics) {
+	w.metrics = m
+}
+
+func (w *WhatsAppClient) setConnected(connected bool) {
+	if w.metrics == nil {
+		return
+	}
+	if connected {
+		w.metrics.WhatsAppConnected.Set(1)
+	} else {
+		w.metrics.WhatsAppConnected.Set(0)
+	}
+}
+
 func (w *WhatsAppClient) eventHandler(evt interface{}) {
 	switch v := evt.(type) {
 	case *events.Message:
@@ -152,14 +186,23 @@ func (w *WhatsAppClient) eventHandler(evt interface{}) {
 			}
 			w.onMessageReceived(v.Info.PushName, v.Info.Sender, v.Info.Chat, msg, v.Info.Timestamp)
 		}
+	case *events.Connected:
+		if w.hasConnected.Swap(true) && w.metrics != nil {
+			w.metrics.WhatsAppReconnects.Inc()
+		}
+		w.setConnected(true)
 	case *events.Disconnected:
 		log.Warn("WhatsApp client was disconnected")
+		w.setConnected(false)
 	case *events.ConnectFailure:
 		log.Warnf("failed to connect to whatsapp: %#v", v.Reason)
+		w.setConnected(false)
 	case *events.LoggedOut:
 		log.Warnf("Received 'loged out' event: %#v", v.Reason)
+		w.setConnected(false)
 	case *events.StreamReplaced:
 		log.Warnf("Another WhatsApp client connected and stole our session! Will reconnect in a few seconds")
+		w.setConnected(false)
 		w.client.Disconnect()
 		time.Sleep(5 * time.Second)
 		err := w.initAndConnectIfNecessary()